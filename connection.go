@@ -0,0 +1,131 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultCollectionKey is the extra url param with the default collection name used by Dial
+const DefaultCollectionKey = "collection"
+
+// Connection describes everything made from the mongo url: client, default database from the url path,
+// default collection from the extra url param and all requested extras
+type Connection struct {
+	Client     *driver.Client
+	DB         *driver.Database   // database from the url path, nil if not set
+	Collection *driver.Collection // collection from the url param, nil if database or collection not set
+	Extras     map[string]interface{}
+}
+
+// WithCollectionKey sets extra url param with the default collection name, DefaultCollectionKey by default.
+// Used by Dial only.
+func WithCollectionKey(key string) ConnectOption {
+	return func(p *connectParams) {
+		p.collectionKey = key
+	}
+}
+
+// Dial connects to mongo url and returns Connection with client, default database and collection.
+// For url mongodb://127.0.0.1:27017/test?collection=events&debug=true it sets DB to "test" and Collection to "events".
+// Collection param always removed from the url, extras work the same way as for Connect.
+func Dial(ctx context.Context, opts *options.ClientOptions, u string, extras []string, copts ...ConnectOption) (*Connection, error) {
+	p := connectParams{collectionKey: DefaultCollectionKey}
+	for _, opt := range copts {
+		opt(&p)
+	}
+
+	mongoURL, extMap, err := cutExtras(u, append([]string{p.collectionKey}, extras...))
+	if err != nil {
+		return nil, fmt.Errorf("can't parse mongo url %s: %w", RedactURL(u), redactErr(err))
+	}
+	dbName, err := urlDatabase(mongoURL)
+	if err != nil {
+		return nil, fmt.Errorf("can't get database from mongo url %s: %w", RedactURL(u), err)
+	}
+
+	collName, _ := extMap[p.collectionKey].(string)
+	delete(extMap, p.collectionKey)
+
+	client, err := connect(ctx, opts, mongoURL, copts)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Connection{Client: client, Extras: extMap}
+	if dbName != "" {
+		res.DB = client.Database(dbName)
+		if collName != "" {
+			res.Collection = res.DB.Collection(collName)
+		}
+	}
+	return res, nil
+}
+
+// BufferedWriter makes BufferedWriterMongo for the default database and collection
+func (c *Connection) BufferedWriter(size int) (*BufferedWriterMongo, error) {
+	if c.Collection == nil {
+		return nil, errors.New("no default database or collection in mongo url")
+	}
+	return NewBufferedWriter(c.Client, c.DB.Name(), c.Collection.Name(), size), nil
+}
+
+// Close disconnects the client
+func (c *Connection) Close(ctx context.Context) error {
+	return c.Client.Disconnect(ctx)
+}
+
+// cutExtras removes extra params from the url query and returns them decoded. Unlike parseExtMongoURI it doesn't
+// use url.Parse, so urls with multiple hosts supported, and the rest of the query kept as is.
+// The first value of the param used, empty values skipped, as url.Values.Get does.
+func cutExtras(u string, extras []string) (mongoURL string, ex map[string]interface{}, err error) {
+	if u == "" {
+		return "", nil, errors.New("empty url")
+	}
+	m, err := parseMongoURI(u)
+	if err != nil {
+		return "", nil, err
+	}
+
+	isExtra := map[string]bool{}
+	for _, e := range extras {
+		isExtra[e] = true
+	}
+	exMap, seen := map[string]interface{}{}, map[string]bool{}
+	query := []string{}
+	for _, kv := range strings.Split(m.query, "&") {
+		if kv == "" {
+			continue
+		}
+		rawKey, rawVal, _ := strings.Cut(kv, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil || !isExtra[key] {
+			query = append(query, kv)
+			continue
+		}
+		val, err := url.QueryUnescape(rawVal)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid %s value: %w", key, err)
+		}
+		if !seen[key] && val != "" {
+			exMap[key] = val
+		}
+		seen[key] = true
+	}
+	m.query = strings.Join(query, "&")
+	return m.String(), exMap, nil
+}
+
+// urlDatabase returns decoded database name from mongo url path, empty if not set
+func urlDatabase(u string) (string, error) {
+	m, err := parseMongoURI(u)
+	if err != nil {
+		return "", err
+	}
+	return url.PathUnescape(strings.TrimPrefix(m.path, "/"))
+}
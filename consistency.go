@@ -0,0 +1,206 @@
+package mongo
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// extra url params with consistency presets
+const (
+	ExtraReadPref     = "app_read"          // read preference mode, i.e. secondaryPreferred
+	ExtraMaxStaleness = "app_max_staleness" // max staleness of secondaries as duration, i.e. 90s
+	ExtraReadTags     = "app_read_tags"     // read preference tags, i.e. dc:east,rack:1
+	ExtraReadConcern  = "app_rc"            // read concern level, i.e. majority
+	ExtraWriteConcern = "app_wc"            // write concern, "majority", number of nodes or tag set name
+	ExtraWriteTimeout = "app_wtimeout"      // write concern timeout as duration, i.e. 2s
+	ExtraWriteJournal = "app_journal"       // write concern journal flag
+)
+
+// ConsistencyExtras lists all extra url params with consistency presets, to pass as extras to Connect
+var ConsistencyExtras = []string{ExtraReadPref, ExtraMaxStaleness, ExtraReadTags, ExtraReadConcern, ExtraWriteConcern,
+	ExtraWriteTimeout, ExtraWriteJournal}
+
+var readConcernLevels = []string{"local", "available", "majority", "linearizable", "snapshot"}
+
+// Consistency keeps read preference, read and write concerns set by extra url params.
+// Nil values not set in the url and inherited from the client.
+type Consistency struct {
+	ReadPref     *readpref.ReadPref
+	ReadConcern  *readconcern.ReadConcern
+	WriteConcern *writeconcern.WriteConcern
+}
+
+// ConsistencyFromURL parses consistency presets from mongo url,
+// i.e. mongodb://127.0.0.1:27017/test?app_read=secondaryPreferred&app_wc=majority&app_wtimeout=2s
+func ConsistencyFromURL(u string) (Consistency, error) {
+	_, extMap, err := cutExtras(u, ConsistencyExtras)
+	if err != nil {
+		return Consistency{}, fmt.Errorf("can't parse mongo url %s: %w", RedactURL(u), redactErr(err))
+	}
+	return ParseConsistency(extMap)
+}
+
+// ParseConsistency makes Consistency from extras returned by Connect with ConsistencyExtras.
+// All invalid values reported together.
+func ParseConsistency(extras map[string]interface{}) (res Consistency, err error) {
+	get := func(key string) string {
+		v, _ := extras[key].(string)
+		return strings.TrimSpace(v)
+	}
+
+	var errs []error
+	if res.ReadPref, err = parseReadPref(get(ExtraReadPref), get(ExtraMaxStaleness), get(ExtraReadTags)); err != nil {
+		errs = append(errs, err)
+	}
+	if res.ReadConcern, err = parseReadConcern(get(ExtraReadConcern)); err != nil {
+		errs = append(errs, err)
+	}
+	if res.WriteConcern, err = parseWriteConcern(get(ExtraWriteConcern), get(ExtraWriteTimeout), get(ExtraWriteJournal)); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return Consistency{}, errors.Join(errs...)
+	}
+	return res, nil
+}
+
+// DatabaseOptions returns options to pass to client.Database
+func (c Consistency) DatabaseOptions() *options.DatabaseOptions {
+	res := options.Database()
+	if c.ReadPref != nil {
+		res.SetReadPreference(c.ReadPref)
+	}
+	if c.ReadConcern != nil {
+		res.SetReadConcern(c.ReadConcern)
+	}
+	if c.WriteConcern != nil {
+		res.SetWriteConcern(c.WriteConcern)
+	}
+	return res
+}
+
+// CollectionOptions returns options to pass to database.Collection
+func (c Consistency) CollectionOptions() *options.CollectionOptions {
+	res := options.Collection()
+	if c.ReadPref != nil {
+		res.SetReadPreference(c.ReadPref)
+	}
+	if c.ReadConcern != nil {
+		res.SetReadConcern(c.ReadConcern)
+	}
+	if c.WriteConcern != nil {
+		res.SetWriteConcern(c.WriteConcern)
+	}
+	return res
+}
+
+// parseReadPref makes read preference from mode, max staleness and tags. Returns nil if nothing set
+func parseReadPref(mode, staleness, tags string) (*readpref.ReadPref, error) {
+	if mode == "" {
+		if staleness != "" || tags != "" {
+			return nil, fmt.Errorf("%s and %s require %s", ExtraMaxStaleness, ExtraReadTags, ExtraReadPref)
+		}
+		return nil, nil
+	}
+	m, err := readpref.ModeFromString(mode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q, should be one of primary, primaryPreferred, secondary, "+
+			"secondaryPreferred, nearest", ExtraReadPref, mode)
+	}
+
+	opts := []readpref.Option{}
+	if staleness != "" {
+		d, err := time.ParseDuration(staleness)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid %s %q, should be positive duration, i.e. 90s", ExtraMaxStaleness, staleness)
+		}
+		opts = append(opts, readpref.WithMaxStaleness(d))
+	}
+	if tags != "" {
+		pairs := []string{}
+		for _, t := range strings.Split(tags, ",") {
+			k, v, ok := strings.Cut(t, ":")
+			if !ok || strings.TrimSpace(k) == "" {
+				return nil, fmt.Errorf("invalid %s %q, should be comma-separated name:value pairs", ExtraReadTags, tags)
+			}
+			pairs = append(pairs, strings.TrimSpace(k), strings.TrimSpace(v))
+		}
+		opts = append(opts, readpref.WithTags(pairs...))
+	}
+
+	res, err := readpref.New(m, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: %w", ExtraReadPref, mode, err)
+	}
+	return res, nil
+}
+
+// parseReadConcern makes read concern from level. Returns nil if level not set
+func parseReadConcern(level string) (*readconcern.ReadConcern, error) {
+	if level == "" {
+		return nil, nil
+	}
+	for _, l := range readConcernLevels {
+		if strings.EqualFold(l, level) {
+			return &readconcern.ReadConcern{Level: l}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid %s %q, should be one of %s", ExtraReadConcern, level, strings.Join(readConcernLevels, ", "))
+}
+
+// parseWriteConcern makes write concern from w, timeout and journal. Returns nil if nothing set.
+// Timeout and journal require w, as the write concern replaces the client's one as a whole.
+func parseWriteConcern(w, timeout, journal string) (*writeconcern.WriteConcern, error) {
+	if w == "" {
+		if timeout != "" || journal != "" {
+			return nil, fmt.Errorf("%s and %s require %s", ExtraWriteTimeout, ExtraWriteJournal, ExtraWriteConcern)
+		}
+		return nil, nil
+	}
+	wVal, err := parseW(w)
+	if err != nil {
+		return nil, err
+	}
+	res := &writeconcern.WriteConcern{W: wVal}
+	if timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid %s %q, should be duration, i.e. 2s", ExtraWriteTimeout, timeout)
+		}
+		res.WTimeout = d
+	}
+	if journal != "" {
+		j, err := strconv.ParseBool(journal)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q, should be true or false", ExtraWriteJournal, journal)
+		}
+		res.Journal = &j
+	}
+	if res.W == 0 && res.Journal != nil && *res.Journal {
+		return nil, fmt.Errorf("invalid %s 0, unacknowledged writes can't be journaled", ExtraWriteConcern)
+	}
+	return res, nil
+}
+
+// parseW makes w value of write concern: "majority", number of nodes or tag set name
+func parseW(w string) (interface{}, error) {
+	if strings.EqualFold(w, "majority") {
+		return "majority", nil
+	}
+	n, err := strconv.Atoi(w)
+	if err != nil {
+		return w, nil // tag set name
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("invalid %s %q, should be majority, non-negative number or tag set name", ExtraWriteConcern, w)
+	}
+	return n, nil
+}
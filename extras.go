@@ -0,0 +1,200 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MissingExtrasError returned when required extra params are not present in the url
+type MissingExtrasError struct {
+	Keys []string
+}
+
+// Error returns the list of missing keys
+func (e *MissingExtrasError) Error() string {
+	return fmt.Sprintf("missing required url params: %s", strings.Join(e.Keys, ", "))
+}
+
+// ConnectInto connects to mongo url and decodes extra url params into cfg, which should be a pointer to struct.
+// Extra params are discovered from `mongo` struct tags, i.e. `mongo:"ava_db"` or `mongo:"ava_db,required"`,
+// default values are set with `default` tag, i.e. `default:"5s"`.
+// Supported field types are string, bool, ints, uints, floats, time.Duration and slices of them (comma separated).
+// Optional ConnectOption list works the same way as for ConnectWithOptions.
+func ConnectInto(ctx context.Context, opts *options.ClientOptions, u string, cfg interface{},
+	copts ...ConnectOption) (*driver.Client, error) {
+
+	fields, err := extrasFields(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(fields))
+	for _, f := range fields {
+		keys = append(keys, f.key)
+	}
+
+	mongoURL, extMap, err := parseExtMongoURI(u, keys)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse mongo url %s: %w", RedactURL(u), redactErr(err))
+	}
+
+	if err = decodeExtras(fields, extMap); err != nil {
+		return nil, err
+	}
+
+	return connect(ctx, opts, mongoURL, copts)
+}
+
+// extraField is a struct field mapped to the extra url param
+type extraField struct {
+	key      string
+	def      string
+	hasDef   bool
+	required bool
+	value    reflect.Value
+}
+
+// extrasFields collects tagged fields from pointer to struct
+func extrasFields(cfg interface{}) ([]extraField, error) {
+	rv := reflect.ValueOf(cfg)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("extras target should be a non-nil pointer to struct, got %T", cfg)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	res := []extraField{}
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		tag, ok := sf.Tag.Lookup("mongo")
+		if !ok || tag == "-" || !sf.IsExported() {
+			continue
+		}
+		elems := strings.Split(tag, ",")
+		f := extraField{key: strings.TrimSpace(elems[0]), value: rv.Field(i)}
+		if f.key == "" {
+			return nil, fmt.Errorf("empty mongo tag for field %s", sf.Name)
+		}
+		for _, opt := range elems[1:] {
+			switch strings.TrimSpace(opt) {
+			case "required":
+				f.required = true
+			case "":
+			default:
+				return nil, fmt.Errorf("unknown mongo tag option %q for field %s", opt, sf.Name)
+			}
+		}
+		f.def, f.hasDef = sf.Tag.Lookup("default")
+		res = append(res, f)
+	}
+	return res, nil
+}
+
+// decodeExtras sets fields from extras map, applies defaults and checks required fields
+func decodeExtras(fields []extraField, extMap map[string]interface{}) error {
+	missing := []string{}
+	for _, f := range fields {
+		val, ok := extMap[f.key].(string)
+		switch {
+		case ok:
+		case f.hasDef:
+			val = f.def
+		case f.required:
+			missing = append(missing, f.key)
+			continue
+		default:
+			continue
+		}
+		if err := setExtraValue(f.value, val); err != nil {
+			return fmt.Errorf("can't set %s=%q: %w", f.key, val, err)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return &MissingExtrasError{Keys: missing}
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setExtraValue converts string value to the field's type and sets it
+func setExtraValue(v reflect.Value, val string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(val)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Slice:
+		return setExtraSlice(v, val)
+	default:
+		return setExtraNumber(v, val)
+	}
+	return nil
+}
+
+// setExtraNumber parses integer, unsigned or float value with the field's size and sets it
+func setExtraNumber(v reflect.Value, val string) error {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(val, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(val, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(val, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// setExtraSlice splits comma-separated value and sets each element, empty value makes empty slice
+func setExtraSlice(v reflect.Value, val string) error {
+	if v.Type().Elem().Kind() == reflect.Slice {
+		return errors.New("nested slices not supported")
+	}
+	elems := []string{}
+	if val != "" {
+		elems = strings.Split(val, ",")
+	}
+	res := reflect.MakeSlice(v.Type(), len(elems), len(elems))
+	for i, e := range elems {
+		if err := setExtraValue(res.Index(i), strings.TrimSpace(e)); err != nil {
+			return err
+		}
+	}
+	v.Set(res)
+	return nil
+}
@@ -0,0 +1,111 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type testExtras struct {
+	DB      string        `mongo:"ava_db,required"`
+	Coll    string        `mongo:"ava_coll" default:"coll1"`
+	Debug   bool          `mongo:"debug"`
+	Timeout time.Duration `mongo:"timeout" default:"5s"`
+	Limit   int           `mongo:"limit"`
+	Ratio   float64       `mongo:"ratio"`
+	Tags    []string      `mongo:"tags"`
+	Ports   []uint16      `mongo:"ports"`
+	Skip    string        `mongo:"-"`
+	NoTag   string
+}
+
+func TestConnectInto(t *testing.T) {
+	mongoURL := getMongoURL(t) + "/test?ava_db=db1&debug=true&limit=10"
+	var cfg testExtras
+	m, err := ConnectInto(context.Background(), options.Client(), mongoURL, &cfg)
+	require.NoError(t, err)
+	defer m.Disconnect(context.Background())
+	assert.Equal(t, testExtras{DB: "db1", Coll: "coll1", Debug: true, Timeout: 5 * time.Second, Limit: 10}, cfg)
+}
+
+func TestConnectInto_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	var cfg testExtras
+	_, err := ConnectInto(ctx, options.Client(), "mongodb://127.0.0.1:27019/test?debug=true", &cfg)
+	var missErr *MissingExtrasError
+	require.True(t, errors.As(err, &missErr), "missing error expected, got %v", err)
+	assert.Equal(t, []string{"ava_db"}, missErr.Keys)
+	assert.EqualError(t, err, "missing required url params: ava_db")
+
+	_, err = ConnectInto(ctx, options.Client(), "mongodb://127.0.0.1:27019/test?ava_db=db1&limit=abc", &cfg)
+	assert.ErrorContains(t, err, `can't set limit="abc"`)
+
+	_, err = ConnectInto(ctx, options.Client(), "mongodb://127.0.0.1:27019/test", cfg)
+	assert.ErrorContains(t, err, "should be a non-nil pointer to struct")
+
+	_, err = ConnectInto(ctx, options.Client(), "", &cfg)
+	assert.ErrorContains(t, err, "can't parse mongo url")
+
+	bad := struct {
+		Val string `mongo:"val,blah"`
+	}{}
+	_, err = ConnectInto(ctx, options.Client(), "mongodb://127.0.0.1:27019/test", &bad)
+	assert.ErrorContains(t, err, `unknown mongo tag option "blah"`)
+}
+
+func TestDecodeExtras(t *testing.T) {
+	tbl := []struct {
+		name     string
+		mongoURL string
+		cleanURL string
+		out      testExtras
+		err      string
+	}{
+		{
+			name:     "all set",
+			mongoURL: "mongodb://127.0.0.1:27017/test?ssl=true&ava_db=db1&ava_coll=c2&debug=1&timeout=1m&limit=-5&ratio=0.5&tags=a,b,c&ports=1,2",
+			cleanURL: "mongodb://127.0.0.1:27017/test?ssl=true",
+			out: testExtras{DB: "db1", Coll: "c2", Debug: true, Timeout: time.Minute, Limit: -5, Ratio: 0.5,
+				Tags: []string{"a", "b", "c"}, Ports: []uint16{1, 2}},
+		},
+		{
+			name:     "defaults",
+			mongoURL: "mongodb://127.0.0.1:27017/test?ava_db=db1&Skip=xyz",
+			cleanURL: "mongodb://127.0.0.1:27017/test?Skip=xyz",
+			out:      testExtras{DB: "db1", Coll: "coll1", Timeout: 5 * time.Second},
+		},
+		{name: "missing", mongoURL: "mongodb://127.0.0.1:27017/test", err: "missing required url params: ava_db"},
+		{name: "bad bool", mongoURL: "mongodb://127.0.0.1:27017/test?ava_db=db1&debug=yes", err: `can't set debug="yes"`},
+		{name: "bad duration", mongoURL: "mongodb://127.0.0.1:27017/test?ava_db=db1&timeout=5", err: `can't set timeout="5"`},
+		{name: "bad slice", mongoURL: "mongodb://127.0.0.1:27017/test?ava_db=db1&ports=1,-2", err: `can't set ports="1,-2"`},
+		{name: "overflow", mongoURL: "mongodb://127.0.0.1:27017/test?ava_db=db1&ports=100000", err: `can't set ports="100000"`},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg testExtras
+			fields, err := extrasFields(&cfg)
+			require.NoError(t, err)
+			keys := []string{}
+			for _, f := range fields {
+				keys = append(keys, f.key)
+			}
+			cleanURL, extMap, err := parseExtMongoURI(tt.mongoURL, keys)
+			require.NoError(t, err)
+			err = decodeExtras(fields, extMap)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.cleanURL, cleanURL)
+			assert.Equal(t, tt.out, cfg)
+		})
+	}
+}
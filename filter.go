@@ -0,0 +1,317 @@
+package mongo
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	reFilterList   = regexp.MustCompile(`^(\S+)\s+(in|nin)\s*\((.*)\)$`)
+	reFilterExists = regexp.MustCompile(`^(\S+)\s+(!?exists)$`)
+)
+
+// filter operators with symbols, two-char operators first
+var filterOps = []struct{ sym, op string }{
+	{">=", "$gte"}, {"<=", "$lte"}, {"!=", "$ne"}, {"!~", "$not"}, {">", "$gt"}, {"<", "$lt"}, {"=", "$eq"}, {"~", "$regex"},
+}
+
+// PrepFilter parses compact filter expression and returns bson.D for mongo driver.
+// Conditions separated by commas and combined with and, i.e. "age>30,name=foo,tags in (a|b),deleted!=true".
+// Supported conditions:
+//   - field=val, field!=val, field>val, field>=val, field<val, field<=val
+//   - field in (v1|v2), field nin (v1|v2)
+//   - field~regex, field!~regex
+//   - field exists, field !exists
+//
+// Values converted to bool (true, false), null, int64, float64, time.Time (RFC3339 or 2006-01-02) and ObjectID
+// (24 hex chars). Quoted values, i.e. "123" or '123', kept as strings. Commas in values can be escaped as \,
+// Brackets in values should be balanced, unless quoted or in regex wrapped with slashes, i.e. name~/\[/.
+// Several conditions on the same field merged, i.e. "age>30,age<50" makes {age: {$gt: 30, $lt: 50}}.
+// Regex passed to the server as is, only delimiters and options checked, the pattern checked by the server.
+// Only allowed fields accepted, empty allowed list rejects all fields. Use PrepFilterAny to accept any field.
+// Fields starting with $ or containing $ rejected always.
+func PrepFilter(expr string, allowed ...string) (bson.D, error) {
+	return prepFilter(expr, allowed, false)
+}
+
+// PrepFilterAny is the same as PrepFilter, but accepts any field. For trusted input only
+func PrepFilterAny(expr string) (bson.D, error) {
+	return prepFilter(expr, nil, true)
+}
+
+// prepFilter implements PrepFilter, fields not in allowed list accepted if allowAll set
+func prepFilter(expr string, allowed []string, allowAll bool) (bson.D, error) {
+	isAllowed := make(map[string]bool, len(allowed))
+	for _, f := range allowed {
+		isAllowed[f] = true
+	}
+
+	conds, err := splitFilter(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter %q: %w", expr, err)
+	}
+
+	res := bson.D{}
+	index := map[string]int{} // field -> position in res
+	for _, cond := range conds {
+		cond = strings.TrimSpace(cond)
+		if cond == "" {
+			continue
+		}
+		field, val, err := parseFilterCond(cond)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter condition %q: %w", cond, err)
+		}
+		if err := checkFilterField(field); err != nil {
+			return nil, fmt.Errorf("invalid filter condition %q: %w", cond, err)
+		}
+		if !allowAll && !isAllowed[field] {
+			return nil, fmt.Errorf("invalid filter condition %q: field %q not allowed", cond, field)
+		}
+
+		i, found := index[field]
+		if !found {
+			index[field] = len(res)
+			res = append(res, bson.E{Key: field, Value: val})
+			continue
+		}
+		merged, err := mergeFilterConds(res[i].Value, val)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter condition %q: %w", cond, err)
+		}
+		res[i].Value = merged
+	}
+	return res, nil
+}
+
+// parseFilterCond parses a single condition and returns field with its value, plain for equality
+// or bson.D with operator
+func parseFilterCond(cond string) (field string, val interface{}, err error) {
+	if m := reFilterList.FindStringSubmatch(cond); m != nil {
+		items := []interface{}{}
+		for _, v := range strings.Split(m[3], "|") {
+			if v = strings.TrimSpace(v); v != "" {
+				items = append(items, filterValue(v))
+			}
+		}
+		if len(items) == 0 {
+			return "", nil, errors.New("empty list")
+		}
+		return m[1], bson.D{{Key: "$" + m[2], Value: items}}, nil
+	}
+
+	if m := reFilterExists.FindStringSubmatch(cond); m != nil {
+		return m[1], bson.D{{Key: "$exists", Value: m[2] == "exists"}}, nil
+	}
+
+	pos := strings.IndexAny(cond, "=<>!~")
+	if pos < 0 {
+		return "", nil, errors.New("no operator")
+	}
+	field = strings.TrimSpace(cond[:pos])
+	for _, op := range filterOps {
+		if !strings.HasPrefix(cond[pos:], op.sym) {
+			continue
+		}
+		raw := strings.TrimSpace(cond[pos+len(op.sym):])
+		switch op.op {
+		case "$regex", "$not":
+			re, err := filterRegex(raw)
+			if err != nil {
+				return "", nil, err
+			}
+			return field, bson.D{{Key: op.op, Value: re}}, nil
+		case "$eq":
+			return field, filterValue(raw), nil
+		default:
+			return field, bson.D{{Key: op.op, Value: filterValue(raw)}}, nil
+		}
+	}
+	return "", nil, fmt.Errorf("unknown operator at %q", cond[pos:])
+}
+
+// filterValue converts raw value to bool, null, number, date or ObjectID, string if nothing matched
+func filterValue(raw string) interface{} {
+	if len(raw) >= 2 && (raw[0] == '"' || raw[0] == '\'') && raw[len(raw)-1] == raw[0] {
+		return raw[1 : len(raw)-1]
+	}
+	switch raw {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return n
+	}
+	if oid, err := primitive.ObjectIDFromHex(raw); err == nil {
+		return oid
+	}
+	if f, err := strconv.ParseFloat(raw, 64); err == nil && !strings.ContainsAny(raw, "nN") { // skip NaN and Inf
+		return f
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t
+	}
+	if t, err := time.Parse("2006-01-02", raw); err == nil {
+		return t
+	}
+	return raw
+}
+
+// filterRegex checks regex delimiters and options and makes primitive.Regex. Case-insensitive flag can be set as
+// /pattern/i. The pattern not compiled, as the server uses PCRE with lookarounds and backreferences not supported by regexp
+func filterRegex(raw string) (primitive.Regex, error) {
+	pattern, opts := raw, ""
+	if len(raw) > 1 && raw[0] == '/' {
+		if i := strings.LastIndex(raw, "/"); i > 0 {
+			pattern, opts = raw[1:i], raw[i+1:]
+		}
+	}
+	if pattern == "" {
+		return primitive.Regex{}, errors.New("empty regex")
+	}
+	if strings.Trim(opts, "imsx") != "" {
+		return primitive.Regex{}, fmt.Errorf("invalid regex options %q", opts)
+	}
+	return primitive.Regex{Pattern: pattern, Options: opts}, nil
+}
+
+// mergeFilterConds combines two conditions on the same field into one operators document.
+// Equality converted to $eq, the same operator used twice is an error.
+func mergeFilterConds(v1, v2 interface{}) (bson.D, error) {
+	ops := func(v interface{}) bson.D {
+		if d, ok := v.(bson.D); ok {
+			return d
+		}
+		return bson.D{{Key: "$eq", Value: v}}
+	}
+	res := append(bson.D{}, ops(v1)...)
+	for _, e := range ops(v2) {
+		for _, r := range res {
+			if r.Key == e.Key {
+				return nil, fmt.Errorf("duplicate %s", e.Key)
+			}
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// checkFilterField rejects empty fields and fields with $, to prevent operator injection
+func checkFilterField(field string) error {
+	if field == "" {
+		return errors.New("empty field")
+	}
+	if strings.Contains(field, "$") {
+		return fmt.Errorf("field %q can't contain $", field)
+	}
+	if strings.ContainsAny(field, " \t=<>!~()|,\"'") || strings.HasPrefix(field, ".") || strings.HasSuffix(field, ".") ||
+		strings.Contains(field, "..") {
+		return fmt.Errorf("invalid field %q", field)
+	}
+	return nil
+}
+
+// splitFilter splits expression by commas outside of brackets, quoted values and /regex/. Escaped commas (\,)
+// kept as commas. Brackets inside quoted values and /regex/ not counted, unbalanced brackets and unclosed quotes
+// or regex rejected.
+func splitFilter(expr string) ([]string, error) {
+	s := filterSplitter{res: []string{}}
+	for i := 0; i < len(expr); i++ {
+		switch {
+		case expr[i] == '\\' && i+1 < len(expr) && expr[i+1] == ',':
+			s.cur.WriteByte(',')
+			i++
+		case s.quote != 0:
+			s.quoted(expr[i])
+		case s.regex:
+			i = s.inRegex(expr, i)
+		default:
+			if err := s.plain(expr[i], i); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s.finish()
+}
+
+// filterSplitter keeps state of splitFilter
+type filterSplitter struct {
+	res     []string
+	cur     strings.Builder // current condition
+	closers []byte          // expected closing brackets, the last one is the innermost
+	quote   byte            // opening quote char if inside quoted value
+	regex   bool            // inside /regex/
+}
+
+// quoted handles char inside quoted value
+func (s *filterSplitter) quoted(c byte) {
+	if c == s.quote {
+		s.quote = 0
+	}
+	s.cur.WriteByte(c)
+}
+
+// inRegex handles char at pos inside /regex/ and returns position of the last handled char.
+// Escaped char, i.e. \/ or \[, handled together with backslash
+func (s *filterSplitter) inRegex(expr string, pos int) int {
+	if expr[pos] == '\\' && pos+1 < len(expr) {
+		s.cur.WriteString(expr[pos : pos+2])
+		return pos + 1
+	}
+	s.regex = expr[pos] != '/'
+	s.cur.WriteByte(expr[pos])
+	return pos
+}
+
+// plain handles char at pos outside of quoted values and regex
+func (s *filterSplitter) plain(c byte, pos int) error {
+	switch {
+	case (c == '"' || c == '\'') && valueStart(s.cur.String()):
+		s.quote = c
+	case c == '/' && strings.HasSuffix(strings.TrimRight(s.cur.String(), " \t"), "~"):
+		s.regex = true
+	case strings.IndexByte("([{", c) >= 0:
+		s.closers = append(s.closers, ")]}"[strings.IndexByte("([{", c)])
+	case c == ')' || c == ']' || c == '}':
+		if len(s.closers) == 0 || s.closers[len(s.closers)-1] != c {
+			return fmt.Errorf("unbalanced %q at %d", string(c), pos)
+		}
+		s.closers = s.closers[:len(s.closers)-1]
+	case c == ',' && len(s.closers) == 0:
+		s.res = append(s.res, s.cur.String())
+		s.cur.Reset()
+		return nil
+	}
+	s.cur.WriteByte(c)
+	return nil
+}
+
+// finish rejects unclosed quote, regex or brackets and returns all conditions
+func (s *filterSplitter) finish() ([]string, error) {
+	switch {
+	case s.quote != 0:
+		return nil, fmt.Errorf("unclosed quote %q", string(s.quote))
+	case s.regex:
+		return nil, errors.New("unclosed regex")
+	case len(s.closers) > 0:
+		return nil, fmt.Errorf("unclosed bracket, %q expected", string(s.closers[len(s.closers)-1]))
+	}
+	return append(s.res, s.cur.String()), nil
+}
+
+// valueStart checks if the next char of condition starts a value, i.e. follows operator or list delimiter
+func valueStart(cond string) bool {
+	cond = strings.TrimRight(cond, " \t")
+	return cond != "" && strings.ContainsRune("=<>~(|", rune(cond[len(cond)-1]))
+}
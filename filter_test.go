@@ -0,0 +1,125 @@
+package mongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPrepFilter(t *testing.T) {
+	oid, err := primitive.ObjectIDFromHex("5f1e9a3b2c4d5e6f7a8b9c0d")
+	require.NoError(t, err)
+
+	tbl := []struct {
+		name string
+		inp  string
+		out  bson.D
+	}{
+		{"empty", "", bson.D{}},
+		{"example", "age>30,name=foo,tags in (a|b),deleted!=true", bson.D{{"age", bson.D{{"$gt", int64(30)}}},
+			{"name", "foo"}, {"tags", bson.D{{"$in", []interface{}{"a", "b"}}}}, {"deleted", bson.D{{"$ne", true}}}}},
+		{"comparisons", "a>=1.5, b<=-2 ,c<x", bson.D{{"a", bson.D{{"$gte", 1.5}}}, {"b", bson.D{{"$lte", int64(-2)}}},
+			{"c", bson.D{{"$lt", "x"}}}}},
+		{"nin", "status nin (1| 2 |draft)", bson.D{{"status", bson.D{{"$nin", []interface{}{int64(1), int64(2), "draft"}}}}}},
+		{"exists", "meta exists,deleted !exists", bson.D{{"meta", bson.D{{"$exists", true}}},
+			{"deleted", bson.D{{"$exists", false}}}}},
+		{"regex", "name~^foo,title!~/bar$/i", bson.D{{"name", bson.D{{"$regex", primitive.Regex{Pattern: "^foo"}}}},
+			{"title", bson.D{{"$not", primitive.Regex{Pattern: "bar$", Options: "i"}}}}}},
+		{"pcre regex", `name~/^(?=f)(o)\1/,title~/a++/`, bson.D{{"name", bson.D{{"$regex", primitive.Regex{Pattern: `^(?=f)(o)\1`}}}},
+			{"title", bson.D{{"$regex", primitive.Regex{Pattern: "a++"}}}}}},
+		{"types", "id=5f1e9a3b2c4d5e6f7a8b9c0d,day=2024-01-02,ts>2024-01-02T10:20:30Z,x=null,s=\"123\",q='true'",
+			bson.D{{"id", oid}, {"day", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+				{"ts", bson.D{{"$gt", time.Date(2024, 1, 2, 10, 20, 30, 0, time.UTC)}}}, {"x", nil}, {"s", "123"}, {"q", "true"}}},
+		{"not a number", "a=NaN,b=Inf,c=1.2.3", bson.D{{"a", "NaN"}, {"b", "Inf"}, {"c", "1.2.3"}}},
+		{"escaped and quoted commas", `a=x\,y,b="p,q",c=o'brien`, bson.D{{"a", "x,y"}, {"b", "p,q"}, {"c", "o'brien"}}},
+		{"brackets in regex", `name~/\[/,age>3,title!~/[(,]\//`, bson.D{{"name", bson.D{{"$regex", primitive.Regex{Pattern: `\[`}}}},
+			{"age", bson.D{{"$gt", int64(3)}}}, {"title", bson.D{{"$not", primitive.Regex{Pattern: `[(,]\/`}}}}}},
+		{"brackets in quotes", `name="a(b",tags in ('x]'|y),age>3`, bson.D{{"name", "a(b"},
+			{"tags", bson.D{{"$in", []interface{}{"x]", "y"}}}}, {"age", bson.D{{"$gt", int64(3)}}}}},
+		{"merged", "age>30,age<50,name=foo,name!=bar", bson.D{{"age", bson.D{{"$gt", int64(30)}, {"$lt", int64(50)}}},
+			{"name", bson.D{{"$eq", "foo"}, {"$ne", "bar"}}}}},
+		{"nested field", "meta.created>2024-01-02", bson.D{{"meta.created",
+			bson.D{{"$gt", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}}}}},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := PrepFilterAny(tt.inp)
+			require.NoError(t, err)
+			assert.Equal(t, tt.out, out)
+		})
+	}
+}
+
+func TestPrepFilter_Errors(t *testing.T) {
+	fields := []string{"age", "name", "tags", "a", "a.b", "a.$ne", "$where"}
+	tbl := []struct {
+		inp     string
+		allowed []string
+		err     string
+	}{
+		{"age", fields, `invalid filter condition "age": no operator`},
+		{"age!30", fields, `invalid filter condition "age!30": unknown operator at "!30"`},
+		{"=30", fields, `invalid filter condition "=30": empty field`},
+		{"$where=1", fields, `invalid filter condition "$where=1": field "$where" can't contain $`},
+		{"a.$ne=1", fields, `invalid filter condition "a.$ne=1": field "a.$ne" can't contain $`},
+		{"a..b=1", fields, `invalid filter condition "a..b=1": invalid field "a..b"`},
+		{"tags in ()", fields, `invalid filter condition "tags in ()": empty list`},
+		{"name~(,age>3", fields, `invalid filter "name~(,age>3": unclosed bracket, ")" expected`},
+		{"name=a),age>3", fields, `invalid filter "name=a),age>3": unbalanced ")" at 6`},
+		{"tags in (a|b],age>3", fields, `invalid filter "tags in (a|b],age>3": unbalanced "]" at 12`},
+		{"name~/[a,age>3", fields, `invalid filter "name~/[a,age>3": unclosed regex`},
+		{`name="a,age>3`, fields, `invalid filter "name=\"a,age>3": unclosed quote "\""`},
+		{"name~/foo/g", fields, `invalid filter condition "name~/foo/g": invalid regex options "g"`},
+		{"name~", fields, `invalid filter condition "name~": empty regex`},
+		{"age>1,age>2", fields, `invalid filter condition "age>2": duplicate $gt`},
+		{"a=1,a=2", fields, `invalid filter condition "a=2": duplicate $eq`},
+		{"name=foo,password=x", []string{"name"}, `invalid filter condition "password=x": field "password" not allowed`},
+		{"name=foo", nil, `invalid filter condition "name=foo": field "name" not allowed`},
+		{"name=foo in (x)", fields, `invalid filter condition "name=foo in (x)": invalid field "name=foo"`},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.inp, func(t *testing.T) {
+			_, err := PrepFilter(tt.inp, tt.allowed...)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+
+	out, err := PrepFilter("name=foo", "name", "age")
+	require.NoError(t, err)
+	assert.Equal(t, bson.D{{"name", "foo"}}, out)
+
+	_, err = PrepFilterAny("$where=1")
+	assert.EqualError(t, err, `invalid filter condition "$where=1": field "$where" can't contain $`)
+}
+
+func TestPrepFilter_Find(t *testing.T) {
+	_, coll, teardown := MakeTestConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	_, err := coll.InsertMany(ctx, []interface{}{
+		bson.M{"name": "foo", "age": 35, "tags": []string{"a", "c"}},
+		bson.M{"name": "bar", "age": 25, "tags": []string{"b"}},
+		bson.M{"name": "baz", "age": 45, "deleted": true},
+	})
+	require.NoError(t, err)
+
+	filter, err := PrepFilter("age>30,deleted!=true,name~^f", "age", "deleted", "name")
+	require.NoError(t, err)
+	count, err := coll.CountDocuments(ctx, filter)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	filter, err = PrepFilterAny("tags in (a|b),deleted !exists")
+	require.NoError(t, err)
+	count, err = coll.CountDocuments(ctx, filter)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
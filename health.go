@@ -0,0 +1,195 @@
+package mongo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"golang.org/x/sync/singleflight"
+)
+
+// HealthTarget is a client checked by HealthChecker. Topology and Metrics are optional and add topology
+// and connection pool stats to the report.
+type HealthTarget struct {
+	Client   *driver.Client
+	Provider ClientProvider // used instead of Client if set, i.e. ClientManager
+	Topology *TopologyMonitor
+	Metrics  *Metrics
+}
+
+// HealthOptions defines ping timeout and caching of HealthChecker
+type HealthOptions struct {
+	Timeout  time.Duration // ping timeout, default 5s
+	CacheTTL time.Duration // how long the last report returned without new pings, not cached if 0
+}
+
+// HealthReport is a result of health check for all clients
+type HealthReport struct {
+	Status  HealthStatus            `json:"status"` // the worst status of all clients
+	Time    time.Time               `json:"time"`
+	Clients map[string]ClientHealth `json:"clients"`
+}
+
+// ClientHealth is a result of health check for a single client
+type ClientHealth struct {
+	Status   HealthStatus  `json:"status"`
+	Latency  time.Duration `json:"latency"` // ping round trip
+	Error    string        `json:"error,omitempty"`
+	Topology *Health       `json:"topology,omitempty"`
+	Pool     *PoolStats    `json:"pool,omitempty"`
+}
+
+// HealthChecker pings named mongo clients and reports their health. Can be used as http.Handler responding
+// with json report, status code 503 if any client is down. Thread safe
+type HealthChecker struct {
+	targets map[string]HealthTarget
+	opts    HealthOptions
+
+	group singleflight.Group // concurrent checks share a single probe
+	lock  sync.Mutex         // protects last
+	last  *HealthReport
+}
+
+// NewHealthChecker makes health checker for named targets
+func NewHealthChecker(targets map[string]HealthTarget, opts HealthOptions) *HealthChecker {
+	if opts.Timeout == 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	res := &HealthChecker{targets: make(map[string]HealthTarget, len(targets)), opts: opts}
+	for name, t := range targets {
+		res.targets[name] = t
+	}
+	return res
+}
+
+// Check pings all clients concurrently and returns the report. Returns cached report if it is younger than CacheTTL.
+// Concurrent calls share a single running check. The check is not canceled with ctx, pings limited by Timeout only,
+// so a canceled caller doesn't make the report down for others. If ctx is done before the check completes,
+// the report with unknown status returned, not cached.
+func (h *HealthChecker) Check(ctx context.Context) HealthReport {
+	if rep, ok := h.cached(); ok {
+		return rep
+	}
+
+	probe := context.WithoutCancel(ctx)
+	resCh := h.group.DoChan("check", func() (interface{}, error) {
+		rep := h.check(probe)
+		h.lock.Lock()
+		h.last = &rep
+		h.lock.Unlock()
+		return rep, nil
+	})
+	select {
+	case res := <-resCh:
+		return res.Val.(HealthReport).copy()
+	case <-ctx.Done():
+		return HealthReport{Status: HealthUnknown, Time: time.Now(), Clients: map[string]ClientHealth{}}
+	}
+}
+
+// cached returns the last report if it is younger than CacheTTL
+func (h *HealthChecker) cached() (HealthReport, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.last != nil && h.opts.CacheTTL > 0 && time.Since(h.last.Time) < h.opts.CacheTTL {
+		return h.last.copy(), true
+	}
+	return HealthReport{}, false
+}
+
+// check pings all targets concurrently
+func (h *HealthChecker) check(ctx context.Context) HealthReport {
+	res := HealthReport{Status: HealthOK, Time: time.Now(), Clients: make(map[string]ClientHealth, len(h.targets))}
+	resLock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for name, t := range h.targets {
+		wg.Add(1)
+		go func(name string, t HealthTarget) {
+			defer wg.Done()
+			ch := h.checkTarget(ctx, t)
+			resLock.Lock()
+			res.Clients[name] = ch
+			resLock.Unlock()
+		}(name, t)
+	}
+	wg.Wait()
+
+	for _, ch := range res.Clients {
+		res.Status = worseHealth(res.Status, ch.Status)
+	}
+	return res
+}
+
+// ServeHTTP responds with json health report, status code 503 if any client is down
+func (h *HealthChecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rep := h.Check(r.Context())
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	if rep.Status == HealthDown {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(rep)
+}
+
+func (h *HealthChecker) checkTarget(ctx context.Context, t HealthTarget) ClientHealth {
+	res := ClientHealth{Status: HealthOK}
+	if t.Topology != nil {
+		topology := t.Topology.Health()
+		res.Topology = &topology
+	}
+	if t.Metrics != nil {
+		pool := t.Metrics.Snapshot().Pool
+		res.Pool = &pool
+	}
+
+	client := t.Client
+	if t.Provider != nil {
+		client = t.Provider.Client()
+	}
+	if client == nil {
+		res.Status, res.Error = HealthDown, "no client"
+		return res
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.opts.Timeout)
+	defer cancel()
+	st := time.Now()
+	err := client.Ping(ctx, readpref.Primary()) // primary regardless of client's read preference, to check writes
+	res.Latency = time.Since(st)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = errors.New("ping timeout")
+		}
+		res.Status, res.Error = HealthDown, err.Error()
+		return res
+	}
+
+	// ping reached primary, the topology can only make it degraded
+	if res.Topology != nil && res.Topology.Status == HealthDegraded {
+		res.Status = HealthDegraded
+	}
+	return res
+}
+
+func (r HealthReport) copy() HealthReport {
+	res := r
+	res.Clients = make(map[string]ClientHealth, len(r.Clients))
+	for k, v := range r.Clients {
+		res.Clients[k] = v
+	}
+	return res
+}
+
+// worseHealth returns the worse of two statuses, down > degraded > unknown > ok
+func worseHealth(a, b HealthStatus) HealthStatus {
+	rank := map[HealthStatus]int{HealthOK: 0, HealthUnknown: 1, HealthDegraded: 2, HealthDown: 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
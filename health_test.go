@@ -0,0 +1,145 @@
+package mongo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestHealthChecker_Connect(t *testing.T) {
+	mongoURL := getMongoURL(t) + "/test"
+	ctx := context.Background()
+
+	tm, metrics := NewTopologyMonitor(TopologyOptions{}), NewMetrics()
+	m, _, err := ConnectWithOptions(ctx, options.Client(), mongoURL, nil, WithTopologyMonitor(tm), WithMetrics(metrics))
+	require.NoError(t, err)
+	defer m.Disconnect(ctx)
+
+	hc := NewHealthChecker(map[string]HealthTarget{"main": {Client: m, Topology: tm, Metrics: metrics}}, HealthOptions{})
+	rep := hc.Check(ctx)
+	t.Logf("%+v", rep)
+	assert.Equal(t, HealthOK, rep.Status)
+	mc := rep.Clients["main"]
+	assert.Equal(t, HealthOK, mc.Status)
+	assert.Greater(t, mc.Latency, time.Duration(0))
+	require.NotNil(t, mc.Topology)
+	require.NotNil(t, mc.Pool)
+	assert.Greater(t, mc.Pool.Created, int64(0))
+}
+
+func TestHealthChecker_Down(t *testing.T) {
+	ctx := context.Background()
+	client, err := driver.Connect(ctx, options.Client().ApplyURI("mongodb://127.0.0.1:1").SetServerSelectionTimeout(time.Second))
+	require.NoError(t, err)
+	defer client.Disconnect(ctx)
+
+	hc := NewHealthChecker(map[string]HealthTarget{"bad": {Client: client}, "none": {}},
+		HealthOptions{Timeout: 50 * time.Millisecond, CacheTTL: time.Minute})
+
+	rep := hc.Check(ctx)
+	assert.Equal(t, HealthDown, rep.Status)
+	require.Len(t, rep.Clients, 2)
+	assert.Equal(t, ClientHealth{Status: HealthDown, Error: "no client"}, rep.Clients["none"])
+	assert.Equal(t, HealthDown, rep.Clients["bad"].Status)
+	assert.Equal(t, "ping timeout", rep.Clients["bad"].Error)
+	assert.Nil(t, rep.Clients["bad"].Topology)
+
+	rep.Clients["bad"] = ClientHealth{Status: HealthOK}
+	cached := hc.Check(ctx)
+	assert.Equal(t, rep.Time, cached.Time, "cached report returned")
+	assert.Equal(t, HealthDown, cached.Clients["bad"].Status, "cached report is a copy")
+
+	rr := httptest.NewRecorder()
+	hc.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/health", http.NoBody))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "application/json; charset=utf-8", rr.Header().Get("Content-Type"))
+	resp := HealthReport{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, HealthDown, resp.Status)
+	assert.Equal(t, "no client", resp.Clients["none"].Error)
+}
+
+// blockingProvider counts calls and blocks them till released, returning no client
+type blockingProvider struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (p *blockingProvider) Client() *driver.Client {
+	p.calls.Add(1)
+	<-p.release
+	return nil
+}
+
+func TestHealthChecker_SharedCheck(t *testing.T) {
+	p := &blockingProvider{release: make(chan struct{})}
+	hc := NewHealthChecker(map[string]HealthTarget{"main": {Provider: p}}, HealthOptions{})
+
+	reports := make([]HealthReport, 10)
+	wg := sync.WaitGroup{}
+	for i := range reports {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reports[i] = hc.Check(context.Background())
+		}(i)
+	}
+	require.Eventually(t, func() bool { return p.calls.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond) // let all checks join the running one
+	close(p.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), p.calls.Load(), "concurrent checks share a single probe")
+	for _, rep := range reports {
+		assert.Equal(t, HealthDown, rep.Status)
+		assert.Equal(t, "no client", rep.Clients["main"].Error)
+	}
+}
+
+func TestHealthChecker_CanceledCaller(t *testing.T) {
+	p := &blockingProvider{release: make(chan struct{})}
+	hc := NewHealthChecker(map[string]HealthTarget{"main": {Provider: p}}, HealthOptions{CacheTTL: time.Minute})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		require.Eventually(t, func() bool { return p.calls.Load() == 1 }, time.Second, time.Millisecond)
+		cancel()
+	}()
+	rep := hc.Check(ctx)
+	assert.Equal(t, HealthUnknown, rep.Status, "canceled caller gets unknown status")
+	assert.Empty(t, rep.Clients)
+
+	close(p.release)
+	rep = hc.Check(context.Background())
+	assert.Equal(t, HealthDown, rep.Status)
+	assert.Equal(t, "no client", rep.Clients["main"].Error, "result of the probe, not of canceled caller")
+	assert.Equal(t, int32(1), p.calls.Load(), "probe continued after caller canceled and its result cached")
+}
+
+func TestHealthChecker_Empty(t *testing.T) {
+	hc := NewHealthChecker(nil, HealthOptions{})
+	rr := httptest.NewRecorder()
+	hc.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/health", http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	resp := HealthReport{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, HealthOK, resp.Status)
+}
+
+func TestWorseHealth(t *testing.T) {
+	assert.Equal(t, HealthDegraded, worseHealth(HealthOK, HealthDegraded))
+	assert.Equal(t, HealthDegraded, worseHealth(HealthDegraded, HealthOK))
+	assert.Equal(t, HealthDown, worseHealth(HealthDegraded, HealthDown))
+	assert.Equal(t, HealthUnknown, worseHealth(HealthOK, HealthUnknown))
+	assert.Equal(t, HealthOK, worseHealth(HealthOK, HealthOK))
+}
@@ -0,0 +1,374 @@
+package mongo
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// index key types set by prefix in the spec, i.e. "@2dsphere:loc"
+var indexKeyTypes = map[string]string{
+	"$text":     "text",
+	"@2dsphere": "2dsphere",
+	"@2d":       "2d",
+	"#hashed":   "hashed",
+	"*wildcard": "wildcard",
+}
+
+// PrepIndexStrict is the same as PrepIndex, but supports special index types and returns error for malformed specs.
+// Each key can be [+|-]field as for PrepSort or one of:
+//   - $text:field or $text:field=weight, text index key with optional weight
+//   - @2dsphere:field and @2d:field, geospatial index keys
+//   - #hashed:field, hashed index key
+//   - *wildcard:path.$** or *wildcard:$**, wildcard index key
+//
+// Keys can be passed separately or comma-separated, i.e. PrepIndexStrict("$text:title=10,$text:body", "-created").
+// Special index types can be combined with regular keys, but not with each other. 2d key should be the first one.
+// Weights set for text keys only. It is a separate function as PrepIndex returns no error and passes fields as is,
+// i.e. "$text:title" stays a regular key, and changing it would break existing callers.
+func PrepIndexStrict(keys ...string) (driver.IndexModel, error) {
+	res, weights, err := parseIndexKeys(keys...)
+	if err != nil {
+		return driver.IndexModel{}, err
+	}
+	m := driver.IndexModel{Keys: res}
+	if len(weights) > 0 {
+		m.Options = options.Index().SetWeights(weights)
+	}
+	return m, nil
+}
+
+// collationSpec is options.Collation with field names used by mongo, to parse and compare collation json
+type collationSpec struct {
+	Locale          string `bson:"locale,omitempty"`
+	CaseLevel       bool   `bson:"caseLevel,omitempty"`
+	CaseFirst       string `bson:"caseFirst,omitempty"`
+	Strength        int    `bson:"strength,omitempty"`
+	NumericOrdering bool   `bson:"numericOrdering,omitempty"`
+	Alternate       string `bson:"alternate,omitempty"`
+	MaxVariable     string `bson:"maxVariable,omitempty"`
+	Normalization   bool   `bson:"normalization,omitempty"`
+	Backwards       bool   `bson:"backwards,omitempty"`
+}
+
+// ParseIndex makes index model with options from spec in form of keys[!flags][;option=value...], i.e.
+// "email!unique,sparse", "created;ttl=720h", "status,-created;name=status_created;partial={\"deleted\":false}".
+// Keys parsed with PrepIndexStrict. Flags are unique, sparse and hidden. Options are:
+//   - name=index_name
+//   - ttl=duration, sets expireAfterSeconds, for a single regular key only
+//   - partial={json}, partialFilterExpression in extended json
+//   - collation=locale or collation={json}, i.e. collation={"locale":"en","strength":2}
+func ParseIndex(spec string) (driver.IndexModel, error) {
+	keysFlags, opts, _ := strings.Cut(spec, ";")
+	keysSpec, flags, _ := strings.Cut(keysFlags, "!")
+
+	m, err := PrepIndexStrict(keysSpec)
+	if err != nil {
+		return driver.IndexModel{}, fmt.Errorf("invalid index %q: %w", spec, err)
+	}
+	if m.Options == nil {
+		m.Options = options.Index()
+	}
+
+	if err = setIndexFlags(m, flags); err != nil {
+		return driver.IndexModel{}, fmt.Errorf("invalid index %q: %w", spec, err)
+	}
+	kv, err := splitIndexOptions(opts)
+	if err != nil {
+		return driver.IndexModel{}, fmt.Errorf("invalid index %q: %w", spec, err)
+	}
+	for _, o := range kv {
+		if err := setIndexOption(m, o[0], o[1]); err != nil {
+			return driver.IndexModel{}, fmt.Errorf("invalid index %q: %w", spec, err)
+		}
+	}
+	if err := checkIndexOptions(m); err != nil {
+		return driver.IndexModel{}, fmt.Errorf("invalid index %q: %w", spec, err)
+	}
+	return m, nil
+}
+
+// setIndexFlags sets comma-separated flags of ParseIndex spec
+func setIndexFlags(m driver.IndexModel, flags string) error {
+	for _, f := range strings.Split(flags, ",") {
+		switch strings.TrimSpace(f) {
+		case "":
+		case "unique":
+			m.Options.SetUnique(true)
+		case "sparse":
+			m.Options.SetSparse(true)
+		case "hidden":
+			m.Options.SetHidden(true)
+		default:
+			return fmt.Errorf("unknown flag %q", strings.TrimSpace(f))
+		}
+	}
+	return nil
+}
+
+// checkIndexOptions rejects ttl for compound or special keys and unique hashed and text indexes
+func checkIndexOptions(m driver.IndexModel) error {
+	keys := m.Keys.(bson.D)
+	if m.Options.ExpireAfterSeconds != nil && (len(keys) != 1 || keys[0].Value != 1 && keys[0].Value != -1) {
+		return errors.New("ttl allowed for a single regular key only")
+	}
+	if m.Options.Unique == nil || !*m.Options.Unique {
+		return nil
+	}
+	for _, k := range keys {
+		if k.Value == "hashed" || k.Value == "text" {
+			return fmt.Errorf("%s index can't be unique", k.Value)
+		}
+	}
+	return nil
+}
+
+// setIndexOption sets a single option of ParseIndex spec
+func setIndexOption(m driver.IndexModel, name, val string) error {
+	switch name {
+	case "name":
+		if val == "" {
+			return errors.New("empty name")
+		}
+		m.Options.SetName(val)
+	case "ttl":
+		d, err := time.ParseDuration(val)
+		if err != nil || d < 0 || d%time.Second != 0 || d.Seconds() > float64(1<<31-1) {
+			return fmt.Errorf("invalid ttl %q, should be non-negative duration in whole seconds", val)
+		}
+		m.Options.SetExpireAfterSeconds(int32(d.Seconds()))
+	case "partial":
+		filter := bson.D{}
+		if err := bson.UnmarshalExtJSON([]byte(val), false, &filter); err != nil {
+			return fmt.Errorf("invalid partial filter %s: %w", val, err)
+		}
+		if len(filter) == 0 {
+			return errors.New("empty partial filter")
+		}
+		m.Options.SetPartialFilterExpression(filter)
+	case "collation":
+		c, err := parseCollation(val)
+		if err != nil {
+			return err
+		}
+		m.Options.SetCollation(c)
+	default:
+		return fmt.Errorf("unknown option %q", name)
+	}
+	return nil
+}
+
+// parseCollation makes collation from locale or json, i.e. {"locale":"en","strength":2}
+func parseCollation(val string) (*options.Collation, error) {
+	if !strings.HasPrefix(val, "{") {
+		if val == "" {
+			return nil, errors.New("empty collation")
+		}
+		return &options.Collation{Locale: val}, nil
+	}
+	c := collationSpec{}
+	if err := bson.UnmarshalExtJSON([]byte(val), false, &c); err != nil {
+		return nil, fmt.Errorf("invalid collation %s: %w", val, err)
+	}
+	if c.Locale == "" {
+		return nil, fmt.Errorf("invalid collation %s: no locale", val)
+	}
+	collation := options.Collation(c)
+	return &collation, nil
+}
+
+// splitIndexOptions splits options of index spec to name and value pairs. Options separated by ;
+// json values can contain ; inside of braces and quotes
+func splitIndexOptions(opts string) (res [][2]string, err error) {
+	for opts = strings.TrimSpace(opts); opts != ""; opts = strings.TrimSpace(opts) {
+		name, rest, found := strings.Cut(opts, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" || strings.Contains(name, ";") {
+			return nil, fmt.Errorf("invalid option %q, should be name=value", strings.SplitN(opts, ";", 2)[0])
+		}
+		rest = strings.TrimLeft(rest, " \t")
+		end := strings.Index(rest, ";")
+		if strings.HasPrefix(rest, "{") {
+			if end = jsonEnd(rest); end < 0 {
+				return nil, fmt.Errorf("unbalanced braces in option %q", name)
+			}
+			if tail := strings.TrimSpace(rest[end:]); tail != "" && tail[0] != ';' {
+				return nil, fmt.Errorf("unexpected %q after option %q", tail, name)
+			}
+		}
+		if end < 0 {
+			end = len(rest)
+		}
+		res = append(res, [2]string{name, strings.TrimSpace(rest[:end])})
+		opts = strings.TrimPrefix(strings.TrimSpace(rest[end:]), ";")
+	}
+	return res, nil
+}
+
+// jsonEnd returns position right after json object at the start of s, -1 if braces not balanced
+func jsonEnd(s string) int {
+	depth, inStr := 0, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case inStr && c == '\\':
+			i++
+		case c == '"':
+			inStr = !inStr
+		case inStr:
+		case c == '{':
+			depth++
+		case c == '}':
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// parseIndexKeys parses index keys spec, returns keys and weights of text keys
+func parseIndexKeys(keys ...string) (res, weights bson.D, err error) {
+	res, weights = bson.D{}, bson.D{}
+	seen := map[string]bool{}
+	special := "" // the only special index type allowed in index
+	for _, s := range keys {
+		for _, k := range strings.Split(s, ",") {
+			k = strings.TrimSpace(k)
+			if k == "" {
+				continue
+			}
+			field, val, weight, err := parseIndexKey(k)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid index key %q: %w", k, err)
+			}
+			if seen[field] {
+				return nil, nil, fmt.Errorf("invalid index key %q: duplicate field %q", k, field)
+			}
+			seen[field] = true
+
+			if typ, ok := val.(string); ok {
+				if err := checkSpecialIndexKey(special, typ, len(res)); err != nil {
+					return nil, nil, fmt.Errorf("invalid index key %q: %w", k, err)
+				}
+				special = typ
+				if typ == "wildcard" {
+					val = 1 // wildcard key is a regular ascending key on $** path
+				}
+			}
+			res = append(res, bson.E{Key: field, Value: val})
+			if weight > 0 {
+				weights = append(weights, bson.E{Key: field, Value: weight})
+			}
+		}
+	}
+	if len(res) == 0 {
+		return nil, nil, errors.New("no index keys")
+	}
+	return res, weights, nil
+}
+
+// checkSpecialIndexKey checks special key of type typ can be added to keys with special type already added.
+// Text keys can be repeated, 2d key should be the first one
+func checkSpecialIndexKey(special, typ string, added int) error {
+	switch {
+	case special != "" && special != typ:
+		return fmt.Errorf("can't combine %s with %s", typ, special)
+	case special == typ && typ != "text":
+		return fmt.Errorf("only one %s key allowed", typ)
+	case typ == "2d" && added > 0:
+		return errors.New("2d key should be the first one")
+	}
+	return nil
+}
+
+// parseIndexKey parses single index key, returns field with value, 1 or -1 for regular keys, index type for special.
+// Weight returned for text key with weight, 0 otherwise
+func parseIndexKey(k string) (field string, val interface{}, weight int, err error) {
+	prefix, rest, special := strings.Cut(k, ":")
+	if !special || prefix == "" || !strings.ContainsAny(prefix[:1], "$@#*") {
+		field, dir, err := parseRegularIndexKey(k)
+		return field, dir, 0, err
+	}
+
+	typ, ok := indexKeyTypes[strings.TrimSpace(prefix)]
+	if !ok {
+		return "", nil, 0, fmt.Errorf("unknown index type %q", prefix)
+	}
+	field = strings.TrimSpace(rest)
+	switch typ {
+	case "text":
+		field, weight, err = parseTextIndexField(field)
+	case "wildcard":
+		err = checkWildcardIndexField(field)
+	default:
+		err = checkIndexField(field)
+	}
+	if err != nil {
+		return "", nil, 0, err
+	}
+	return field, typ, weight, nil
+}
+
+// parseRegularIndexKey parses [+|-]field key, returns field and direction
+func parseRegularIndexKey(k string) (field string, dir int, err error) {
+	dir = 1
+	switch k[0] {
+	case '-':
+		dir, k = -1, k[1:]
+	case '+':
+		k = k[1:]
+	}
+	field = strings.TrimSpace(k)
+	if err := checkIndexField(field); err != nil {
+		return "", 0, err
+	}
+	return field, dir, nil
+}
+
+// parseTextIndexField parses field or field=weight of text key, weight is 0 if not set
+func parseTextIndexField(s string) (field string, weight int, err error) {
+	field, w, found := strings.Cut(s, "=")
+	field = strings.TrimSpace(field)
+	if found {
+		weight, err = strconv.Atoi(strings.TrimSpace(w))
+		if err != nil || weight < 1 {
+			return "", 0, fmt.Errorf("invalid weight %q, should be positive integer", w)
+		}
+	}
+	if err := checkIndexField(field); err != nil {
+		return "", 0, err
+	}
+	return field, weight, nil
+}
+
+// checkWildcardIndexField checks wildcard field is $** or path ending with .$**
+func checkWildcardIndexField(field string) error {
+	if field == "$**" {
+		return nil
+	}
+	if !strings.HasSuffix(field, ".$**") {
+		return fmt.Errorf("wildcard field %q should be $** or end with .$**", field)
+	}
+	return checkIndexField(strings.TrimSuffix(field, ".$**"))
+}
+
+// checkIndexField rejects empty fields, fields with $ and malformed paths
+func checkIndexField(field string) error {
+	if field == "" {
+		return errors.New("empty field")
+	}
+	if strings.Contains(field, "$") {
+		return fmt.Errorf("field %q can't contain $", field)
+	}
+	if strings.ContainsAny(field, " \t=:") || strings.HasPrefix(field, ".") || strings.HasSuffix(field, ".") ||
+		strings.Contains(field, "..") {
+		return fmt.Errorf("invalid field %q", field)
+	}
+	return nil
+}
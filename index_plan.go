@@ -0,0 +1,516 @@
+package mongo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IndexPlan is a diff between declared and existing indexes of a collection, made by PlanIndexes.
+// Printed as text with String and as json with json.Marshal
+type IndexPlan struct {
+	Collection string          `json:"collection"`
+	Create     []IndexChange   `json:"create,omitempty"`    // declared indexes missing in the collection
+	Drop       []IndexChange   `json:"drop,omitempty"`      // existing indexes not declared, except _id_
+	Conflicts  []IndexConflict `json:"conflicts,omitempty"` // declared indexes with different keys or options
+	Unchanged  []string        `json:"unchanged,omitempty"`
+}
+
+// IndexChange describes index to create or drop. Keys and options are relaxed ext json
+type IndexChange struct {
+	Name    string          `json:"name"`
+	Keys    json.RawMessage `json:"keys"`
+	Options json.RawMessage `json:"options,omitempty"`
+}
+
+// IndexConflict describes declared index differing from the existing one
+type IndexConflict struct {
+	Name     string `json:"name"`
+	Existing string `json:"existing"` // name of existing index, the same as Name unless keys matched
+	Reason   string `json:"reason"`
+}
+
+// indexDef is a normalized index definition, comparable for declared and existing indexes
+type indexDef struct {
+	name string
+	keys bson.D // key pattern, text keys replaced with _fts and _ftsx as stored by mongo
+	opts bson.D // options affecting index behavior, sorted by name, defaults omitted
+}
+
+// PlanIndexes compares declared index models with existing indexes of the collection and returns the diff, without
+// changing anything. Indexes matched by name, generated from keys if not set in options, or by key pattern.
+// Matched indexes compared by keys and options affecting index behavior, i.e. unique, sparse, hidden, ttl, partial
+// filter, collation and text weights, ignoring defaults filled by mongo. Collation compared by locale and options
+// set in the declared index only, as defaults of the others depend on locale.
+func PlanIndexes(ctx context.Context, coll *driver.Collection, models []driver.IndexModel) (*IndexPlan, error) {
+	declared, err := declaredIndexDefs(models)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := listIndexDefs(ctx, coll)
+	if err != nil {
+		return nil, err
+	}
+	return diffIndexes(coll.Name(), declared, existing), nil
+}
+
+// HasChanges checks if the plan has indexes to create or drop, or conflicts
+func (p *IndexPlan) HasChanges() bool {
+	return len(p.Create) > 0 || len(p.Drop) > 0 || len(p.Conflicts) > 0
+}
+
+// String returns human-readable plan, a line per index prefixed with + to create, - to drop, ! for conflicts
+// and = for unchanged, i.e.
+//
+//	indexes of users: 1 to create, 1 to drop, 1 conflicts, 1 unchanged
+//	+ email_1 {"email":1} {"unique":true}
+//	- old_1 {"old":1}
+//	! created_1: options {"expireAfterSeconds":7200}, existing {"expireAfterSeconds":3600}
+//	= status_1
+func (p *IndexPlan) String() string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "indexes of %s: %d to create, %d to drop, %d conflicts, %d unchanged\n", p.Collection,
+		len(p.Create), len(p.Drop), len(p.Conflicts), len(p.Unchanged))
+	for _, c := range p.Create {
+		fmt.Fprintf(&b, "+ %s\n", c)
+	}
+	for _, c := range p.Drop {
+		fmt.Fprintf(&b, "- %s\n", c)
+	}
+	for _, c := range p.Conflicts {
+		if c.Existing != c.Name {
+			fmt.Fprintf(&b, "! %s (existing %s): %s\n", c.Name, c.Existing, c.Reason)
+			continue
+		}
+		fmt.Fprintf(&b, "! %s: %s\n", c.Name, c.Reason)
+	}
+	for _, name := range p.Unchanged {
+		fmt.Fprintf(&b, "= %s\n", name)
+	}
+	return b.String()
+}
+
+// String returns index name with keys and options
+func (c IndexChange) String() string {
+	if len(c.Options) == 0 {
+		return fmt.Sprintf("%s %s", c.Name, c.Keys)
+	}
+	return fmt.Sprintf("%s %s %s", c.Name, c.Keys, c.Options)
+}
+
+// diffIndexes compares declared and existing indexes
+func diffIndexes(collection string, declared, existing []indexDef) *IndexPlan {
+	res := &IndexPlan{Collection: collection}
+	names := map[string]bool{}
+	for _, d := range declared {
+		names[d.name] = true
+	}
+	matched := map[string]bool{} // existing indexes matched by declared ones
+	for _, d := range declared {
+		e, found := matchIndex(d, existing, func(name string) bool { return matched[name] || names[name] })
+		if !found {
+			res.Create = append(res.Create, d.change())
+			continue
+		}
+		matched[e.name] = true
+		if reason := compareIndexDefs(d, e); reason != "" {
+			res.Conflicts = append(res.Conflicts, IndexConflict{Name: d.name, Existing: e.name, Reason: reason})
+			continue
+		}
+		res.Unchanged = append(res.Unchanged, d.name)
+	}
+	for _, e := range existing {
+		if !matched[e.name] && e.name != "_id_" {
+			res.Drop = append(res.Drop, e.change())
+		}
+	}
+	return res
+}
+
+// declaredIndexDefs makes normalized definitions of index models, rejecting duplicate names
+func declaredIndexDefs(models []driver.IndexModel) ([]indexDef, error) {
+	res := make([]indexDef, 0, len(models))
+	names := map[string]bool{}
+	for i, m := range models {
+		d, err := declaredIndexDef(m)
+		if err != nil {
+			return nil, fmt.Errorf("invalid index model #%d: %w", i, err)
+		}
+		if names[d.name] {
+			return nil, fmt.Errorf("duplicate index %q", d.name)
+		}
+		names[d.name] = true
+		res = append(res, d)
+	}
+	return res, nil
+}
+
+// change makes IndexChange for the index
+func (d indexDef) change() IndexChange {
+	res := IndexChange{Name: d.name, Keys: json.RawMessage(docString(d.keys))}
+	if len(d.opts) > 0 {
+		res.Options = json.RawMessage(docString(d.opts))
+	}
+	return res
+}
+
+// listIndexDefs returns normalized definitions of all indexes of the collection
+func listIndexDefs(ctx context.Context, coll *driver.Collection) ([]indexDef, error) {
+	cursor, err := coll.Indexes().List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't list indexes of %s: %w", coll.Name(), err)
+	}
+	specs := []bson.Raw{}
+	if err = cursor.All(ctx, &specs); err != nil {
+		return nil, fmt.Errorf("can't read indexes of %s: %w", coll.Name(), err)
+	}
+	res := make([]indexDef, 0, len(specs))
+	for _, s := range specs {
+		d, err := existingIndexDef(s)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, d)
+	}
+	return res, nil
+}
+
+// matchIndex finds existing index for declared one, by name or, if not found, by key pattern.
+// Indexes matched by key pattern only if not skipped, i.e. already matched or declared with other keys
+func matchIndex(d indexDef, existing []indexDef, skip func(name string) bool) (indexDef, bool) {
+	for _, e := range existing {
+		if e.name == d.name {
+			return e, true
+		}
+	}
+	for _, e := range existing {
+		if !skip(e.name) && docString(e.keys) == docString(d.keys) {
+			return e, true
+		}
+	}
+	return indexDef{}, false
+}
+
+// compareIndexDefs returns the reason declared and existing indexes differ, empty if they are the same
+func compareIndexDefs(d, e indexDef) string {
+	var diffs []string
+	if d.name != e.name {
+		diffs = append(diffs, fmt.Sprintf("name %q, existing %q", d.name, e.name))
+	}
+	if docString(d.keys) != docString(e.keys) {
+		diffs = append(diffs, fmt.Sprintf("keys %s, existing %s", docString(d.keys), docString(e.keys)))
+	}
+	if eOpts := declaredCollation(d.opts, e.opts); docString(d.opts) != docString(eOpts) {
+		diffs = append(diffs, fmt.Sprintf("options %s, existing %s", docString(d.opts), docString(eOpts)))
+	}
+	return strings.Join(diffs, "; ")
+}
+
+// declaredCollation returns existing options with collation reduced to fields set in declared collation.
+// Mongo fills unset fields with locale defaults, i.e. backwards for fr_CA or caseFirst for da, so they can't
+// be compared without knowing these defaults.
+func declaredCollation(declared, existing bson.D) bson.D {
+	dc, dFound := lookupDoc(declared, "collation")
+	ec, eFound := lookupDoc(existing, "collation")
+	if !dFound || !eFound {
+		return existing
+	}
+	c := bson.D{}
+	for _, e := range ec {
+		if _, found := lookup(dc, e.Key); found {
+			c = append(c, e)
+		}
+	}
+	return setDoc(append(bson.D{}, existing...), "collation", c)
+}
+
+// declaredIndexDef makes normalized definition from index model, name generated from keys if not set
+func declaredIndexDef(m driver.IndexModel) (indexDef, error) {
+	keys, err := toDoc(m.Keys)
+	if err != nil {
+		return indexDef{}, fmt.Errorf("invalid keys: %w", err)
+	}
+	if len(keys) == 0 {
+		return indexDef{}, errors.New("no keys")
+	}
+
+	spec := bson.D{}
+	name := ""
+	if o := m.Options; o != nil {
+		if o.Name != nil {
+			name = *o.Name
+		}
+		spec = append(spec, indexFlagsSpec(o)...)
+		spec = append(spec, indexTextSpec(o)...)
+		docs, err := indexDocsSpec(o)
+		if err != nil {
+			return indexDef{}, err
+		}
+		spec = append(spec, docs...)
+	}
+	if name == "" {
+		if name, err = indexName(keys); err != nil {
+			return indexDef{}, err
+		}
+	}
+
+	storedKeys, spec := storedTextKeys(keys, spec)
+	return indexDef{name: name, keys: normalizeDoc(storedKeys, false), opts: indexOptionsDef(spec)}, nil
+}
+
+// indexFlagsSpec returns unique, sparse, hidden and expireAfterSeconds options, if set
+func indexFlagsSpec(o *options.IndexOptions) bson.D {
+	res := bson.D{}
+	for _, b := range []struct {
+		key string
+		val *bool
+	}{{"unique", o.Unique}, {"sparse", o.Sparse}, {"hidden", o.Hidden}} {
+		if b.val != nil {
+			res = append(res, bson.E{Key: b.key, Value: *b.val})
+		}
+	}
+	if o.ExpireAfterSeconds != nil {
+		res = append(res, bson.E{Key: "expireAfterSeconds", Value: *o.ExpireAfterSeconds})
+	}
+	return res
+}
+
+// indexTextSpec returns collation and language options, if set
+func indexTextSpec(o *options.IndexOptions) bson.D {
+	res := bson.D{}
+	if o.Collation != nil {
+		res = append(res, bson.E{Key: "collation", Value: collationSpec(*o.Collation)})
+	}
+	if o.DefaultLanguage != nil {
+		res = append(res, bson.E{Key: "default_language", Value: *o.DefaultLanguage})
+	}
+	if o.LanguageOverride != nil {
+		res = append(res, bson.E{Key: "language_override", Value: *o.LanguageOverride})
+	}
+	return res
+}
+
+// indexDocsSpec returns document options, partial filter, weights and wildcard projection, if set
+func indexDocsSpec(o *options.IndexOptions) (bson.D, error) {
+	res := bson.D{}
+	for _, d := range []struct {
+		key string
+		val interface{}
+	}{{"partialFilterExpression", o.PartialFilterExpression}, {"weights", o.Weights},
+		{"wildcardProjection", o.WildcardProjection}} {
+		if d.val == nil {
+			continue
+		}
+		doc, err := toDoc(d.val)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", d.key, err)
+		}
+		res = append(res, bson.E{Key: d.key, Value: doc})
+	}
+	return res, nil
+}
+
+// storedTextKeys returns keys as stored by mongo, with text keys replaced by _fts and _ftsx keys, and spec with
+// weights of all text fields, 1 for text fields without weight
+func storedTextKeys(keys, spec bson.D) (stored, withWeights bson.D) {
+	textKeys := bson.D{}
+	weights, _ := lookupDoc(spec, "weights")
+	stored = bson.D{}
+	for _, k := range keys {
+		if k.Value != "text" {
+			stored = append(stored, k)
+			continue
+		}
+		if len(textKeys) == 0 {
+			stored = append(stored, bson.E{Key: "_fts", Value: "text"}, bson.E{Key: "_ftsx", Value: 1})
+		}
+		w, found := lookup(weights, k.Key)
+		if !found {
+			w = 1
+		}
+		textKeys = append(textKeys, bson.E{Key: k.Key, Value: w})
+	}
+	for _, w := range weights {
+		if _, found := lookup(textKeys, w.Key); !found {
+			textKeys = append(textKeys, w) // weights of fields not in keys, i.e. $**
+		}
+	}
+	if len(textKeys) > 0 {
+		spec = setDoc(spec, "weights", textKeys)
+	}
+	return stored, spec
+}
+
+// existingIndexDef makes normalized definition from index specification returned by listIndexes
+func existingIndexDef(spec bson.Raw) (indexDef, error) {
+	doc := bson.D{}
+	if err := bson.Unmarshal(spec, &doc); err != nil {
+		return indexDef{}, fmt.Errorf("can't decode index spec: %w", err)
+	}
+	name, _ := lookup(doc, "name")
+	keys, _ := lookupDoc(doc, "key")
+	n, ok := name.(string)
+	if !ok || len(keys) == 0 {
+		return indexDef{}, fmt.Errorf("invalid index spec %s", spec)
+	}
+	return indexDef{name: n, keys: normalizeDoc(keys, false), opts: indexOptionsDef(doc)}, nil
+}
+
+// indexOptionsDef extracts options affecting index behavior from spec, in the form listIndexes returns them.
+// Defaults omitted, numbers normalized, documents sorted by keys
+func indexOptionsDef(spec bson.D) bson.D {
+	res := bson.D{}
+	for _, e := range spec {
+		switch e.Key {
+		case "unique", "sparse", "hidden":
+			if e.Value == true {
+				res = append(res, e)
+			}
+		case "expireAfterSeconds":
+			res = append(res, bson.E{Key: e.Key, Value: normalizeValue(e.Value, true)})
+		case "default_language":
+			if e.Value != "english" {
+				res = append(res, e)
+			}
+		case "language_override":
+			if e.Value != "language" {
+				res = append(res, e)
+			}
+		case "collation":
+			c, err := toDoc(e.Value)
+			if err != nil {
+				continue
+			}
+			c = removeKey(c, "version")
+			res = append(res, bson.E{Key: e.Key, Value: normalizeDoc(c, true)})
+		case "partialFilterExpression", "weights", "wildcardProjection":
+			if d, err := toDoc(e.Value); err == nil {
+				res = append(res, bson.E{Key: e.Key, Value: normalizeDoc(d, true)})
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Key < res[j].Key })
+	return res
+}
+
+// indexName generates index name the same way the driver does, i.e. "status_1_created_-1"
+func indexName(keys bson.D) (string, error) {
+	parts := make([]string, 0, len(keys)*2)
+	for _, k := range keys {
+		switch v := k.Value.(type) {
+		case string:
+			parts = append(parts, k.Key, v)
+		case int, int32, int64:
+			parts = append(parts, k.Key, fmt.Sprintf("%d", v))
+		default:
+			return "", fmt.Errorf("invalid value %v of index key %q", k.Value, k.Key)
+		}
+	}
+	return strings.Join(parts, "_"), nil
+}
+
+// toDoc converts document of any type, i.e. bson.M or struct, to bson.D with bson types of values
+func toDoc(v interface{}) (bson.D, error) {
+	if v == nil {
+		return nil, errors.New("nil document")
+	}
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	res := bson.D{}
+	if err = bson.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// normalizeDoc converts numbers to int64, if integral, or float64 recursively, sorting keys of documents if requested
+func normalizeDoc(d bson.D, sortKeys bool) bson.D {
+	res := make(bson.D, 0, len(d))
+	for _, e := range d {
+		res = append(res, bson.E{Key: e.Key, Value: normalizeValue(e.Value, sortKeys)})
+	}
+	if sortKeys {
+		sort.SliceStable(res, func(i, j int) bool { return res[i].Key < res[j].Key })
+	}
+	return res
+}
+
+func normalizeValue(v interface{}, sortKeys bool) interface{} {
+	switch val := v.(type) {
+	case int:
+		return int64(val)
+	case int32:
+		return int64(val)
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) < 1<<53 {
+			return int64(val)
+		}
+		return val
+	case bson.D:
+		return normalizeDoc(val, sortKeys)
+	case bson.A:
+		res := make(bson.A, 0, len(val))
+		for _, a := range val {
+			res = append(res, normalizeValue(a, sortKeys))
+		}
+		return res
+	}
+	return v
+}
+
+// docString returns relaxed ext json of the document, for comparison and reports
+func docString(d bson.D) string {
+	data, err := bson.MarshalExtJSON(d, false, false)
+	if err != nil {
+		return fmt.Sprintf("%v", d)
+	}
+	return string(data)
+}
+
+func lookup(d bson.D, key string) (interface{}, bool) {
+	for _, e := range d {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+func lookupDoc(d bson.D, key string) (bson.D, bool) {
+	v, found := lookup(d, key)
+	if !found {
+		return nil, false
+	}
+	res, ok := v.(bson.D)
+	return res, ok
+}
+
+func setDoc(d bson.D, key string, val interface{}) bson.D {
+	for i, e := range d {
+		if e.Key == key {
+			d[i].Value = val
+			return d
+		}
+	}
+	return append(d, bson.E{Key: key, Value: val})
+}
+
+func removeKey(d bson.D, key string) bson.D {
+	res := make(bson.D, 0, len(d))
+	for _, e := range d {
+		if e.Key != key {
+			res = append(res, e)
+		}
+	}
+	return res
+}
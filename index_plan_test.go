@@ -0,0 +1,217 @@
+package mongo
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestIndexDefs_Compare(t *testing.T) {
+	tbl := []struct {
+		name     string
+		spec     string // ParseIndex spec of declared index
+		existing bson.D // index as returned by listIndexes
+		diff     string // expected difference, empty if the same
+	}{
+		{"same", "status,-created",
+			bson.D{{"v", 2}, {"key", bson.D{{"status", 1}, {"created", -1}}}, {"name", "status_1_created_-1"}}, ""},
+		{"double keys", "status",
+			bson.D{{"v", 2}, {"key", bson.D{{"status", 1.0}}}, {"name", "status_1"}}, ""},
+		{"keys order", "status,-created",
+			bson.D{{"key", bson.D{{"created", -1}, {"status", 1}}}, {"name", "status_1_created_-1"}},
+			`keys {"status":1,"created":-1}, existing {"created":-1,"status":1}`},
+		{"unique", "email!unique",
+			bson.D{{"key", bson.D{{"email", 1}}}, {"name", "email_1"}, {"unique", true}}, ""},
+		{"not unique", "email!unique",
+			bson.D{{"key", bson.D{{"email", 1}}}, {"name", "email_1"}},
+			`options {"unique":true}, existing {}`},
+		{"sparse false", "email",
+			bson.D{{"key", bson.D{{"email", 1}}}, {"name", "email_1"}, {"sparse", false}}, ""},
+		{"ttl", "created;ttl=1h",
+			bson.D{{"key", bson.D{{"created", 1}}}, {"name", "created_1"}, {"expireAfterSeconds", int32(3600)}}, ""},
+		{"ttl changed", "created;ttl=1h",
+			bson.D{{"key", bson.D{{"created", 1}}}, {"name", "created_1"}, {"expireAfterSeconds", int64(60)}},
+			`options {"expireAfterSeconds":3600}, existing {"expireAfterSeconds":60}`},
+		{"partial", `status;name=active;partial={"deleted":false,"age":{"$gt":1}}`,
+			bson.D{{"key", bson.D{{"status", 1}}}, {"name", "active"},
+				{"partialFilterExpression", bson.D{{"age", bson.D{{"$gt", 1}}}, {"deleted", false}}}}, ""},
+		{"name", "status;name=active",
+			bson.D{{"key", bson.D{{"status", 1}}}, {"name", "status_1"}},
+			`name "active", existing "status_1"`},
+		{"collation", `name;collation={"locale":"en","strength":2}`,
+			bson.D{{"key", bson.D{{"name", 1}}}, {"name", "name_1"}, {"collation", bson.D{{"locale", "en"},
+				{"caseLevel", false}, {"caseFirst", "off"}, {"strength", 2}, {"numericOrdering", false},
+				{"alternate", "non-ignorable"}, {"maxVariable", "punct"}, {"normalization", false},
+				{"backwards", false}, {"version", "57.1"}}}}, ""},
+		{"collation strength", `name;collation={"locale":"en","strength":3}`,
+			bson.D{{"key", bson.D{{"name", 1}}}, {"name", "name_1"}, {"collation", bson.D{{"locale", "en"},
+				{"caseLevel", false}, {"strength", 2}, {"version", "57.1"}}}},
+			`options {"collation":{"locale":"en","strength":3}}, existing {"collation":{"locale":"en","strength":2}}`},
+		{"collation locale defaults", `name;collation=fr_CA`,
+			bson.D{{"key", bson.D{{"name", 1}}}, {"name", "name_1"}, {"collation", bson.D{{"locale", "fr_CA"},
+				{"caseLevel", false}, {"caseFirst", "off"}, {"strength", 3}, {"numericOrdering", false},
+				{"alternate", "non-ignorable"}, {"maxVariable", "punct"}, {"normalization", false},
+				{"backwards", true}, {"version", "57.1"}}}}, ""},
+		{"collation locale", `name;collation=en`,
+			bson.D{{"key", bson.D{{"name", 1}}}, {"name", "name_1"}, {"collation", bson.D{{"locale", "de"},
+				{"strength", 3}}}},
+			`options {"collation":{"locale":"en"}}, existing {"collation":{"locale":"de"}}`},
+		{"no collation", `name;collation=en`,
+			bson.D{{"key", bson.D{{"name", 1}}}, {"name", "name_1"}},
+			`options {"collation":{"locale":"en"}}, existing {}`},
+		{"text", "$text:title=10,$text:body,-created",
+			bson.D{{"key", bson.D{{"_fts", "text"}, {"_ftsx", 1}, {"created", -1}}},
+				{"name", "title_text_body_text_created_-1"}, {"weights", bson.D{{"body", 1}, {"title", 10}}},
+				{"default_language", "english"}, {"language_override", "language"}, {"textIndexVersion", 3}}, ""},
+		{"text weight", "$text:title=10",
+			bson.D{{"key", bson.D{{"_fts", "text"}, {"_ftsx", 1}}}, {"name", "title_text"},
+				{"weights", bson.D{{"title", 1}}}},
+			`options {"weights":{"title":10}}, existing {"weights":{"title":1}}`},
+		{"2dsphere", "@2dsphere:loc",
+			bson.D{{"key", bson.D{{"loc", "2dsphere"}}}, {"name", "loc_2dsphere"}, {"2dsphereIndexVersion", 3}}, ""},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseIndex(tt.spec)
+			require.NoError(t, err)
+			d, err := declaredIndexDef(m)
+			require.NoError(t, err)
+			raw, err := bson.Marshal(tt.existing)
+			require.NoError(t, err)
+			e, err := existingIndexDef(raw)
+			require.NoError(t, err)
+			assert.Equal(t, tt.diff, compareIndexDefs(d, e))
+		})
+	}
+}
+
+func TestDeclaredIndexDef(t *testing.T) {
+	d, err := declaredIndexDef(driver.IndexModel{Keys: bson.D{{"status", 1}, {"created", int64(-1)}}})
+	require.NoError(t, err)
+	assert.Equal(t, "status_1_created_-1", d.name)
+	assert.Equal(t, bson.D{{"status", int64(1)}, {"created", int64(-1)}}, d.keys)
+	assert.Equal(t, bson.D{}, d.opts)
+
+	d, err = declaredIndexDef(driver.IndexModel{Keys: bson.M{"email": 1},
+		Options: options.Index().SetName("email").SetUnique(true).SetSparse(false)})
+	require.NoError(t, err)
+	assert.Equal(t, "email", d.name)
+	assert.Equal(t, bson.D{{"unique", true}}, d.opts)
+
+	_, err = declaredIndexDef(driver.IndexModel{Keys: bson.D{}})
+	assert.EqualError(t, err, "no keys")
+	_, err = declaredIndexDef(driver.IndexModel{Keys: bson.D{{"loc", 1.5}}})
+	assert.EqualError(t, err, `invalid value 1.5 of index key "loc"`)
+	_, err = declaredIndexDef(driver.IndexModel{Keys: "status"})
+	assert.Error(t, err)
+}
+
+func TestDiffIndexes(t *testing.T) {
+	declared := []indexDef{}
+	for _, spec := range []string{"email!unique", "status,-created", "created;ttl=2h", "age;name=by_age"} {
+		m, err := ParseIndex(spec)
+		require.NoError(t, err)
+		d, err := declaredIndexDef(m)
+		require.NoError(t, err)
+		declared = append(declared, d)
+	}
+	existing := []indexDef{}
+	for _, spec := range []bson.D{
+		{{"key", bson.D{{"_id", 1}}}, {"name", "_id_"}},
+		{{"key", bson.D{{"status", 1}, {"created", -1}}}, {"name", "status_1_created_-1"}},
+		{{"key", bson.D{{"created", 1}}}, {"name", "created_1"}, {"expireAfterSeconds", 3600}},
+		{{"key", bson.D{{"age", 1}}}, {"name", "age_1"}},
+		{{"key", bson.D{{"old", 1}}}, {"name", "old_1"}, {"sparse", true}},
+	} {
+		raw, err := bson.Marshal(spec)
+		require.NoError(t, err)
+		e, err := existingIndexDef(raw)
+		require.NoError(t, err)
+		existing = append(existing, e)
+	}
+
+	plan := diffIndexes("users", declared, existing)
+	assert.Equal(t, &IndexPlan{
+		Collection: "users",
+		Create: []IndexChange{{Name: "email_1", Keys: json.RawMessage(`{"email":1}`),
+			Options: json.RawMessage(`{"unique":true}`)}},
+		Drop: []IndexChange{{Name: "old_1", Keys: json.RawMessage(`{"old":1}`),
+			Options: json.RawMessage(`{"sparse":true}`)}},
+		Conflicts: []IndexConflict{
+			{Name: "created_1", Existing: "created_1",
+				Reason: `options {"expireAfterSeconds":7200}, existing {"expireAfterSeconds":3600}`},
+			{Name: "by_age", Existing: "age_1", Reason: `name "by_age", existing "age_1"`},
+		},
+		Unchanged: []string{"status_1_created_-1"},
+	}, plan)
+	assert.True(t, plan.HasChanges())
+
+	assert.Equal(t, `indexes of users: 1 to create, 1 to drop, 2 conflicts, 1 unchanged
++ email_1 {"email":1} {"unique":true}
+- old_1 {"old":1} {"sparse":true}
+! created_1: options {"expireAfterSeconds":7200}, existing {"expireAfterSeconds":3600}
+! by_age (existing age_1): name "by_age", existing "age_1"
+= status_1_created_-1
+`, plan.String())
+
+	data, err := json.Marshal(plan)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"collection":"users",
+		"create":[{"name":"email_1","keys":{"email":1},"options":{"unique":true}}],
+		"drop":[{"name":"old_1","keys":{"old":1},"options":{"sparse":true}}],
+		"conflicts":[{"name":"created_1","existing":"created_1",
+			"reason":"options {\"expireAfterSeconds\":7200}, existing {\"expireAfterSeconds\":3600}"},
+			{"name":"by_age","existing":"age_1","reason":"name \"by_age\", existing \"age_1\""}],
+		"unchanged":["status_1_created_-1"]}`, string(data))
+
+	plan = diffIndexes("users", declared[1:2], existing[:2])
+	assert.False(t, plan.HasChanges())
+	assert.Equal(t, "indexes of users: 0 to create, 0 to drop, 0 conflicts, 1 unchanged\n= status_1_created_-1\n",
+		plan.String())
+}
+
+func TestPlanIndexes(t *testing.T) {
+	_, coll, teardown := MakeTestConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	_, err := coll.Indexes().CreateMany(ctx, []driver.IndexModel{
+		{Keys: bson.D{{"email", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"title", "text"}}, Options: options.Index().SetWeights(bson.D{{"title", 5}})},
+		{Keys: bson.D{{"old", 1}}},
+	})
+	require.NoError(t, err)
+
+	models := []driver.IndexModel{}
+	for _, spec := range []string{"email!unique", "$text:title=10", "status"} {
+		m, err := ParseIndex(spec)
+		require.NoError(t, err)
+		models = append(models, m)
+	}
+	plan, err := PlanIndexes(ctx, coll, models)
+	require.NoError(t, err)
+	assert.Equal(t, coll.Name(), plan.Collection)
+	assert.Equal(t, []string{"email_1"}, plan.Unchanged)
+	require.Len(t, plan.Create, 1)
+	assert.Equal(t, "status_1", plan.Create[0].Name)
+	require.Len(t, plan.Drop, 1)
+	assert.Equal(t, "old_1", plan.Drop[0].Name)
+	require.Len(t, plan.Conflicts, 1)
+	assert.Equal(t, IndexConflict{Name: "title_text", Existing: "title_text",
+		Reason: `options {"weights":{"title":10}}, existing {"weights":{"title":5}}`}, plan.Conflicts[0])
+
+	// nothing changed by the plan
+	specs, err := coll.Indexes().ListSpecifications(ctx)
+	require.NoError(t, err)
+	assert.Len(t, specs, 4)
+
+	_, err = PlanIndexes(ctx, coll, []driver.IndexModel{{Keys: bson.D{}}})
+	assert.EqualError(t, err, "invalid index model #0: no keys")
+}
@@ -0,0 +1,93 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureIndexOptions defines what EnsureIndexes allowed to change besides creating missing indexes
+type EnsureIndexOptions struct {
+	DropUndeclared   bool // drop existing indexes not declared in models, _id index never dropped
+	RebuildConflicts bool // drop and create again indexes declared with different keys or options, reported otherwise
+}
+
+// IndexReport describes changes made by EnsureIndexes. All lists contain index names
+type IndexReport struct {
+	Created    []string        `json:"created,omitempty"`
+	Dropped    []string        `json:"dropped,omitempty"`
+	Rebuilt    []string        `json:"rebuilt,omitempty"`
+	Unchanged  []string        `json:"unchanged,omitempty"`
+	Undeclared []string        `json:"undeclared,omitempty"` // existing indexes not declared and kept
+	Conflicts  []IndexConflict `json:"conflicts,omitempty"`  // declared indexes differing from existing and kept
+}
+
+// EnsureIndexes makes indexes of the collection match declared models. It compares models with existing indexes
+// by PlanIndexes and creates missing ones. Models without name matched by the name mongo generates,
+// i.e. "status_1_created_-1". Indexes declared with different keys or options reported as conflicts or,
+// with RebuildConflicts, dropped and created again. Undeclared indexes dropped with DropUndeclared.
+// Returns report of changes, partial on error.
+func EnsureIndexes(ctx context.Context, coll *driver.Collection, models []driver.IndexModel,
+	opts EnsureIndexOptions) (*IndexReport, error) {
+	declared, err := declaredIndexDefs(models)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := listIndexDefs(ctx, coll)
+	if err != nil {
+		return nil, err
+	}
+	plan := diffIndexes(coll.Name(), declared, existing)
+
+	byName := make(map[string]driver.IndexModel, len(models))
+	for i, d := range declared {
+		byName[d.name] = models[i]
+	}
+
+	res := &IndexReport{Unchanged: plan.Unchanged}
+	for _, c := range plan.Drop {
+		if !opts.DropUndeclared {
+			res.Undeclared = append(res.Undeclared, c.Name)
+			continue
+		}
+		if _, err := coll.Indexes().DropOne(ctx, c.Name); err != nil {
+			return res, fmt.Errorf("can't drop index %q: %w", c.Name, err)
+		}
+		res.Dropped = append(res.Dropped, c.Name)
+	}
+
+	toCreate := []string{}
+	for _, c := range plan.Create {
+		toCreate = append(toCreate, c.Name)
+	}
+	for _, c := range plan.Conflicts {
+		if !opts.RebuildConflicts {
+			res.Conflicts = append(res.Conflicts, c)
+			continue
+		}
+		if _, err := coll.Indexes().DropOne(ctx, c.Existing); err != nil {
+			return res, fmt.Errorf("can't drop index %q: %w", c.Existing, err)
+		}
+		toCreate = append(toCreate, c.Name)
+	}
+	for i, name := range toCreate {
+		m := byName[name]
+		io := options.Index()
+		if m.Options != nil {
+			copied := *m.Options // don't change options of the caller
+			io = &copied
+		}
+		m.Options = io.SetName(name)
+		if _, err := coll.Indexes().CreateOne(ctx, m); err != nil {
+			return res, fmt.Errorf("can't create index %q: %w", name, err)
+		}
+		if i < len(plan.Create) {
+			res.Created = append(res.Created, name)
+			continue
+		}
+		res.Rebuilt = append(res.Rebuilt, name)
+	}
+	return res, nil
+}
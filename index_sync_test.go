@@ -0,0 +1,67 @@
+package mongo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	driver "go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestEnsureIndexes(t *testing.T) {
+	_, coll, teardown := MakeTestConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	models := func(specs ...string) []driver.IndexModel {
+		res := []driver.IndexModel{}
+		for _, s := range specs {
+			m, err := ParseIndex(s)
+			require.NoError(t, err)
+			res = append(res, m)
+		}
+		return res
+	}
+
+	res, err := EnsureIndexes(ctx, coll, models("email!unique", "status,-created", "$text:title=10,$text:body",
+		`name;collation={"locale":"en","strength":2}`, "created;ttl=1h"), EnsureIndexOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, &IndexReport{Created: []string{"email_1", "status_1_created_-1", "title_text_body_text",
+		"name_1", "created_1"}}, res)
+
+	// the same indexes unchanged
+	res, err = EnsureIndexes(ctx, coll, models("email!unique", "status,-created", "$text:title=10,$text:body",
+		`name;collation={"locale":"en","strength":2}`, "created;ttl=1h"), EnsureIndexOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, &IndexReport{Unchanged: []string{"email_1", "status_1_created_-1", "title_text_body_text",
+		"name_1", "created_1"}}, res)
+
+	// changed ttl reported as conflict, undeclared indexes kept
+	res, err = EnsureIndexes(ctx, coll, models("email!unique", "created;ttl=2h", "age"), EnsureIndexOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"age_1"}, res.Created)
+	assert.Equal(t, []string{"email_1"}, res.Unchanged)
+	assert.Equal(t, []string{"status_1_created_-1", "title_text_body_text", "name_1"}, res.Undeclared)
+	require.Len(t, res.Conflicts, 1)
+	assert.Equal(t, IndexConflict{Name: "created_1", Existing: "created_1",
+		Reason: `options {"expireAfterSeconds":7200}, existing {"expireAfterSeconds":3600}`}, res.Conflicts[0])
+
+	// conflicts rebuilt, undeclared dropped
+	res, err = EnsureIndexes(ctx, coll, models("email!unique", "created;ttl=2h", "age;name=by_age"),
+		EnsureIndexOptions{DropUndeclared: true, RebuildConflicts: true})
+	require.NoError(t, err)
+	assert.Equal(t, &IndexReport{Unchanged: []string{"email_1"}, Rebuilt: []string{"created_1", "by_age"},
+		Dropped: []string{"status_1_created_-1", "title_text_body_text", "name_1"}}, res)
+
+	specs, err := coll.Indexes().ListSpecifications(ctx)
+	require.NoError(t, err)
+	names := []string{}
+	for _, s := range specs {
+		names = append(names, s.Name)
+	}
+	assert.ElementsMatch(t, []string{"_id_", "email_1", "created_1", "by_age"}, names)
+
+	_, err = EnsureIndexes(ctx, coll, models("email", "email;name=email_1"), EnsureIndexOptions{})
+	assert.EqualError(t, err, `duplicate index "email_1"`)
+}
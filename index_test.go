@@ -0,0 +1,200 @@
+package mongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestPrepIndexStrict(t *testing.T) {
+	tbl := []struct {
+		name string
+		inp  []string
+		out  bson.D
+	}{
+		{"regular", []string{"f1", " -f2", "+f3 "}, bson.D{{"f1", 1}, {"f2", -1}, {"f3", 1}}},
+		{"comma separated", []string{"f1,-f2"}, bson.D{{"f1", 1}, {"f2", -1}}},
+		{"text", []string{"$text:title", "$text:body"}, bson.D{{"title", "text"}, {"body", "text"}}},
+		{"text compound", []string{"status,$text:title"}, bson.D{{"status", 1}, {"title", "text"}}},
+		{"2dsphere", []string{"@2dsphere:loc", "-created"}, bson.D{{"loc", "2dsphere"}, {"created", -1}}},
+		{"2d", []string{"@2d:pos"}, bson.D{{"pos", "2d"}}},
+		{"2d compound", []string{"@2d:pos,type"}, bson.D{{"pos", "2d"}, {"type", 1}}},
+		{"hashed", []string{"#hashed:user_id"}, bson.D{{"user_id", "hashed"}}},
+		{"hashed compound", []string{"tenant,#hashed:user_id"}, bson.D{{"tenant", 1}, {"user_id", "hashed"}}},
+		{"wildcard", []string{"*wildcard:attrs.$**"}, bson.D{{"attrs.$**", 1}}},
+		{"wildcard all", []string{"*wildcard:$**"}, bson.D{{"$**", 1}}},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := PrepIndexStrict(tt.inp...)
+			require.NoError(t, err)
+			assert.Equal(t, driver.IndexModel{Keys: tt.out}, out)
+		})
+	}
+
+	out, err := PrepIndexStrict("$text:title=10,$text:body=2", "$text:tags")
+	require.NoError(t, err)
+	assert.Equal(t, bson.D{{"title", "text"}, {"body", "text"}, {"tags", "text"}}, out.Keys)
+	require.NotNil(t, out.Options)
+	assert.Equal(t, bson.D{{"title", 10}, {"body", 2}}, out.Options.Weights)
+}
+
+func TestPrepIndexStrict_Errors(t *testing.T) {
+	tbl := []struct {
+		inp []string
+		err string
+	}{
+		{nil, "no index keys"},
+		{[]string{" , "}, "no index keys"},
+		{[]string{"-"}, `invalid index key "-": empty field`},
+		{[]string{"$where"}, `invalid index key "$where": field "$where" can't contain $`},
+		{[]string{"a:b"}, `invalid index key "a:b": invalid field "a:b"`},
+		{[]string{"a..b"}, `invalid index key "a..b": invalid field "a..b"`},
+		{[]string{"$text:"}, `invalid index key "$text:": empty field`},
+		{[]string{"@geo:loc"}, `invalid index key "@geo:loc": unknown index type "@geo"`},
+		{[]string{"$text:title=0"}, `invalid index key "$text:title=0": invalid weight "0", should be positive integer`},
+		{[]string{"$text:title=x"}, `invalid index key "$text:title=x": invalid weight "x", should be positive integer`},
+		{[]string{"#hashed:id=5"}, `invalid index key "#hashed:id=5": invalid field "id=5"`},
+		{[]string{"*wildcard:attrs"}, `invalid index key "*wildcard:attrs": wildcard field "attrs" should be $** or end with .$**`},
+		{[]string{"*wildcard:a$.$**"}, `invalid index key "*wildcard:a$.$**": field "a$" can't contain $`},
+		{[]string{"a", "-a"}, `invalid index key "-a": duplicate field "a"`},
+		{[]string{"#hashed:a,#hashed:b"}, `invalid index key "#hashed:b": only one hashed key allowed`},
+		{[]string{"$text:a,@2dsphere:loc"}, `invalid index key "@2dsphere:loc": can't combine 2dsphere with text`},
+		{[]string{"*wildcard:$**,#hashed:b"}, `invalid index key "#hashed:b": can't combine hashed with wildcard`},
+		{[]string{"type", "@2d:pos"}, `invalid index key "@2d:pos": 2d key should be the first one`},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.err, func(t *testing.T) {
+			_, err := PrepIndexStrict(tt.inp...)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestPrepIndexStrict_Create(t *testing.T) {
+	_, coll, teardown := MakeTestConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	for _, spec := range []string{"$text:title=10,$text:body", "@2dsphere:loc", "#hashed:user_id", "*wildcard:attrs.$**"} {
+		m, err := PrepIndexStrict(spec)
+		require.NoError(t, err)
+		_, err = coll.Indexes().CreateOne(ctx, m)
+		require.NoError(t, err, spec)
+	}
+
+	_, err := coll.InsertOne(ctx, bson.M{"title": "hello world", "body": "text", "loc": bson.M{"type": "Point",
+		"coordinates": []float64{10, 20}}, "user_id": 1, "attrs": bson.M{"color": "red"}})
+	require.NoError(t, err)
+	count, err := coll.CountDocuments(ctx, bson.M{"$text": bson.M{"$search": "hello"}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	specs, err := coll.Indexes().ListSpecifications(ctx, options.ListIndexes())
+	require.NoError(t, err)
+	assert.Len(t, specs, 5)
+}
+
+func TestParseIndex(t *testing.T) {
+	m, err := ParseIndex("email!unique,sparse")
+	require.NoError(t, err)
+	assert.Equal(t, bson.D{{"email", 1}}, m.Keys)
+	assert.Equal(t, options.Index().SetUnique(true).SetSparse(true), m.Options)
+
+	m, err = ParseIndex("-created;ttl=720h")
+	require.NoError(t, err)
+	assert.Equal(t, bson.D{{"created", -1}}, m.Keys)
+	assert.Equal(t, options.Index().SetExpireAfterSeconds(int32((720 * time.Hour).Seconds())), m.Options)
+
+	m, err = ParseIndex(`status, -created !hidden; name = status_created ; partial={"deleted": false, "note": "a;b}"}`)
+	require.NoError(t, err)
+	assert.Equal(t, bson.D{{"status", 1}, {"created", -1}}, m.Keys)
+	assert.Equal(t, options.Index().SetHidden(true).SetName("status_created").
+		SetPartialFilterExpression(bson.D{{"deleted", false}, {"note", "a;b}"}}), m.Options)
+
+	m, err = ParseIndex(`name;collation={"locale":"en","strength":2,"caseLevel":true,"numericOrdering":true};name=by_name`)
+	require.NoError(t, err)
+	assert.Equal(t, options.Index().SetCollation(&options.Collation{Locale: "en", Strength: 2, CaseLevel: true,
+		NumericOrdering: true}).SetName("by_name"), m.Options)
+
+	m, err = ParseIndex("name;collation=fr")
+	require.NoError(t, err)
+	assert.Equal(t, &options.Collation{Locale: "fr"}, m.Options.Collation)
+
+	m, err = ParseIndex("$text:title=5,$text:body;name=search")
+	require.NoError(t, err)
+	assert.Equal(t, options.Index().SetWeights(bson.D{{"title", 5}}).SetName("search"), m.Options)
+
+	m, err = ParseIndex("a,b")
+	require.NoError(t, err)
+	assert.Equal(t, options.Index(), m.Options)
+}
+
+func TestParseIndex_Errors(t *testing.T) {
+	tbl := []struct {
+		inp string
+		err string
+	}{
+		{"", `invalid index "": no index keys`},
+		{"!unique", `invalid index "!unique": no index keys`},
+		{"a!uniq", `invalid index "a!uniq": unknown flag "uniq"`},
+		{"a;ttl", `invalid index "a;ttl": invalid option "ttl", should be name=value`},
+		{"a;=x", `invalid index "a;=x": invalid option "=x", should be name=value`},
+		{"a;foo=bar", `invalid index "a;foo=bar": unknown option "foo"`},
+		{"a;name=", `invalid index "a;name=": empty name`},
+		{"a;ttl=1.5s", `invalid index "a;ttl=1.5s": invalid ttl "1.5s", should be non-negative duration in whole seconds`},
+		{"a;ttl=-1h", `invalid index "a;ttl=-1h": invalid ttl "-1h", should be non-negative duration in whole seconds`},
+		{"a,b;ttl=1h", `invalid index "a,b;ttl=1h": ttl allowed for a single regular key only`},
+		{"#hashed:a;ttl=1h", `invalid index "#hashed:a;ttl=1h": ttl allowed for a single regular key only`},
+		{"#hashed:a!unique", `invalid index "#hashed:a!unique": hashed index can't be unique`},
+		{`a;partial={"x":1`, `invalid index "a;partial={\"x\":1": unbalanced braces in option "partial"`},
+		{`a;partial={}`, `invalid index "a;partial={}": empty partial filter`},
+		{`a;partial={"x":1} y`, `invalid index "a;partial={\"x\":1} y": unexpected "y" after option "partial"`},
+		{`a;collation={"strength":2}`, `invalid index "a;collation={\"strength\":2}": invalid collation {"strength":2}: no locale`},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.inp, func(t *testing.T) {
+			_, err := ParseIndex(tt.inp)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+
+	_, err := ParseIndex(`a;partial={bad json}`)
+	assert.ErrorContains(t, err, "invalid partial filter {bad json}")
+}
+
+func TestParseIndex_Create(t *testing.T) {
+	_, coll, teardown := MakeTestConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	for _, spec := range []string{"email!unique,sparse", "created;ttl=720h", `status;partial={"deleted":false};name=active`,
+		`name;collation={"locale":"en","strength":2}`} {
+		m, err := ParseIndex(spec)
+		require.NoError(t, err)
+		_, err = coll.Indexes().CreateOne(ctx, m)
+		require.NoError(t, err, spec)
+	}
+
+	specs, err := coll.Indexes().ListSpecifications(ctx)
+	require.NoError(t, err)
+	require.Len(t, specs, 5)
+	byName := map[string]*driver.IndexSpecification{}
+	for _, s := range specs {
+		byName[s.Name] = s
+	}
+	require.NotNil(t, byName["email_1"])
+	assert.True(t, *byName["email_1"].Unique)
+	assert.True(t, *byName["email_1"].Sparse)
+	assert.Equal(t, int32(720*3600), *byName["created_1"].ExpireAfterSeconds)
+	require.NotNil(t, byName["active"])
+}
@@ -0,0 +1,162 @@
+package mongo
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Keyset makes filters for keyset (cursor-based) pagination. The next page starts right after the last document
+// of the previous one, by values of sort keys passed in the opaque page token. Thread safe
+type Keyset struct {
+	sort bson.D
+}
+
+// keysetToken is a content of page token, sort spec and values of sort keys of the last document
+type keysetToken struct {
+	Sort   []string        `bson:"s"`
+	Values []bson.RawValue `bson:"v"`
+}
+
+// NewKeyset makes Keyset for sort spec in PrepSort syntax, i.e. "-created,_id". If _id is not the last key
+// it is added with direction of the last key, to break ties. Empty, duplicated and $-prefixed keys rejected.
+// Sort keys should be set in all documents and have a single type, as $gt and $lt match values of the same type
+// only, and documents with null or other type of the key would be skipped.
+func NewKeyset(sort ...string) (*Keyset, error) {
+	keys := []string{}
+	for _, s := range sort {
+		keys = append(keys, strings.Split(s, ",")...)
+	}
+	res := PrepSort(keys...)
+	seen := map[string]bool{}
+	for _, e := range res {
+		switch {
+		case e.Key == "":
+			return nil, errors.New("empty sort key")
+		case strings.HasPrefix(e.Key, "$"):
+			return nil, fmt.Errorf("sort key %q can't start with $", e.Key)
+		case seen[e.Key]:
+			return nil, fmt.Errorf("duplicate sort key %q", e.Key)
+		}
+		seen[e.Key] = true
+	}
+	if len(res) == 0 {
+		return &Keyset{sort: bson.D{{Key: "_id", Value: 1}}}, nil
+	}
+	if seen["_id"] && res[len(res)-1].Key != "_id" {
+		return nil, errors.New("_id should be the last sort key")
+	}
+	if !seen["_id"] {
+		res = append(res, bson.E{Key: "_id", Value: res[len(res)-1].Value})
+	}
+	return &Keyset{sort: res}, nil
+}
+
+// Sort returns sort document with _id as the last key
+func (k *Keyset) Sort() bson.D {
+	return append(bson.D{}, k.sort...)
+}
+
+// FindOptions returns find options with keyset sort and limit
+func (k *Keyset) FindOptions(limit int64) *options.FindOptions {
+	return options.Find().SetSort(k.Sort()).SetLimit(limit)
+}
+
+// Token makes opaque page token, base64 ext JSON with values of sort keys of the last document of a page.
+// Last document can be a struct, map, bson.D or bson.Raw and should have all sort keys.
+func (k *Keyset) Token(last interface{}) (string, error) {
+	raw, ok := last.(bson.Raw)
+	if !ok {
+		data, err := bson.Marshal(last)
+		if err != nil {
+			return "", fmt.Errorf("can't marshal last document: %w", err)
+		}
+		raw = data
+	}
+
+	tok := keysetToken{Sort: k.spec()}
+	for _, e := range k.sort {
+		val, err := raw.LookupErr(strings.Split(e.Key, ".")...)
+		if err != nil {
+			return "", fmt.Errorf("no sort key %q in the last document: %w", e.Key, err)
+		}
+		if err = checkKeysetValue(val); err != nil {
+			return "", fmt.Errorf("sort key %q in the last document: %w", e.Key, err)
+		}
+		tok.Values = append(tok.Values, val)
+	}
+	data, err := bson.MarshalExtJSON(tok, true, false)
+	if err != nil {
+		return "", fmt.Errorf("can't marshal page token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// Filter makes filter for the page after the token, empty filter for empty token, i.e. for the first page.
+// For sort -created,_id it is {$or: [{created: {$lt: c}}, {created: {$eq: c}, _id: {$gt: id}}]}.
+// Token is not signed and comes from the client, so values are used only with explicit $eq, $gt and $lt,
+// and documents and arrays rejected, to prevent operator injection. Null values rejected too, as nothing is
+// greater or less than null. Combine it with other conditions using $and.
+func (k *Keyset) Filter(token string) (bson.D, error) {
+	if token == "" {
+		return bson.D{}, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page token: %w", err)
+	}
+	tok := keysetToken{}
+	if err = bson.UnmarshalExtJSON(data, true, &tok); err != nil {
+		return nil, fmt.Errorf("invalid page token: %w", err)
+	}
+	if strings.Join(tok.Sort, ",") != strings.Join(k.spec(), ",") || len(tok.Values) != len(k.sort) {
+		return nil, fmt.Errorf("page token made for sort %q, not %q", strings.Join(tok.Sort, ","), strings.Join(k.spec(), ","))
+	}
+	for i, v := range tok.Values {
+		if err = checkKeysetValue(v); err != nil {
+			return nil, fmt.Errorf("invalid page token, sort key %q: %w", k.sort[i].Key, err)
+		}
+	}
+
+	or := bson.A{}
+	for i, e := range k.sort {
+		cond := bson.D{}
+		for j := 0; j < i; j++ {
+			cond = append(cond, bson.E{Key: k.sort[j].Key, Value: bson.D{{Key: "$eq", Value: tok.Values[j]}}})
+		}
+		op := "$gt"
+		if e.Value == -1 {
+			op = "$lt"
+		}
+		cond = append(cond, bson.E{Key: e.Key, Value: bson.D{{Key: op, Value: tok.Values[i]}}})
+		or = append(or, cond)
+	}
+	return bson.D{{Key: "$or", Value: or}}, nil
+}
+
+// checkKeysetValue rejects documents and arrays, not usable as keyset values and able to carry operators,
+// and null values, not comparable with $gt and $lt
+func checkKeysetValue(v bson.RawValue) error {
+	switch v.Type {
+	case bson.TypeEmbeddedDocument, bson.TypeArray, bson.TypeNull, bson.TypeUndefined:
+		return fmt.Errorf("unsupported value type %s", v.Type)
+	}
+	return nil
+}
+
+// spec returns sort as a list of keys with direction prefix, i.e. ["-created", "+_id"]
+func (k *Keyset) spec() []string {
+	res := make([]string, 0, len(k.sort))
+	for _, e := range k.sort {
+		if e.Value == -1 {
+			res = append(res, "-"+e.Key)
+			continue
+		}
+		res = append(res, "+"+e.Key)
+	}
+	return res
+}
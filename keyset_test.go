@@ -0,0 +1,175 @@
+package mongo
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNewKeyset(t *testing.T) {
+	tbl := []struct {
+		inp  []string
+		sort bson.D
+		err  string
+	}{
+		{nil, bson.D{{"_id", 1}}, ""},
+		{[]string{"-created,_id"}, bson.D{{"created", -1}, {"_id", 1}}, ""},
+		{[]string{"-created"}, bson.D{{"created", -1}, {"_id", -1}}, ""},
+		{[]string{"name", "-age"}, bson.D{{"name", 1}, {"age", -1}, {"_id", -1}}, ""},
+		{[]string{"-_id"}, bson.D{{"_id", -1}}, ""},
+		{[]string{"-"}, nil, "empty sort key"},
+		{[]string{"a,a"}, nil, `duplicate sort key "a"`},
+		{[]string{"$natural"}, nil, `sort key "$natural" can't start with $`},
+		{[]string{"_id,name"}, nil, "_id should be the last sort key"},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.err, func(t *testing.T) {
+			k, err := NewKeyset(tt.inp...)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.sort, k.Sort())
+		})
+	}
+}
+
+func TestKeyset_Filter(t *testing.T) {
+	k, err := NewKeyset("-created,name")
+	require.NoError(t, err)
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	oid := primitive.NewObjectID()
+	token, err := k.Token(struct {
+		ID      primitive.ObjectID `bson:"_id"`
+		Created time.Time          `bson:"created"`
+		Name    string             `bson:"name"`
+		Other   int                `bson:"other"`
+	}{ID: oid, Created: created, Name: "foo", Other: 1})
+	require.NoError(t, err)
+
+	filter, err := k.Filter(token)
+	require.NoError(t, err)
+	data, err := bson.Marshal(filter)
+	require.NoError(t, err)
+	got := bson.D{}
+	require.NoError(t, bson.Unmarshal(data, &got))
+	exp := bson.D{{"$or", bson.A{
+		bson.D{{"created", bson.D{{"$lt", primitive.NewDateTimeFromTime(created)}}}},
+		bson.D{{"created", bson.D{{"$eq", primitive.NewDateTimeFromTime(created)}}}, {"name", bson.D{{"$gt", "foo"}}}},
+		bson.D{{"created", bson.D{{"$eq", primitive.NewDateTimeFromTime(created)}}}, {"name", bson.D{{"$eq", "foo"}}},
+			{"_id", bson.D{{"$gt", oid}}}},
+	}}}
+	assert.Equal(t, exp, got)
+
+	filter, err = k.Filter("")
+	require.NoError(t, err)
+	assert.Equal(t, bson.D{}, filter, "first page")
+
+	_, err = k.Filter("not base64!")
+	assert.ErrorContains(t, err, "invalid page token")
+	_, err = k.Filter(base64.RawURLEncoding.EncodeToString([]byte("{bad json")))
+	assert.ErrorContains(t, err, "invalid page token")
+
+	other, err := NewKeyset("created")
+	require.NoError(t, err)
+	_, err = other.Filter(token)
+	assert.EqualError(t, err, `page token made for sort "-created,+name,+_id", not "+created,+_id"`)
+
+	_, err = k.Token(bson.M{"created": created, "_id": oid})
+	assert.ErrorContains(t, err, `no sort key "name" in the last document`)
+	_, err = k.Token(bson.M{"created": created, "name": bson.A{"a", "b"}, "_id": oid})
+	assert.EqualError(t, err, `sort key "name" in the last document: unsupported value type array`)
+	_, err = k.Token(bson.M{"created": created, "name": nil, "_id": oid})
+	assert.EqualError(t, err, `sort key "name" in the last document: unsupported value type null`)
+}
+
+func TestKeyset_ForgedToken(t *testing.T) {
+	k, err := NewKeyset("name")
+	require.NoError(t, err)
+
+	tbl := []struct {
+		name  string
+		token string
+		err   string
+	}{
+		{"operator in document", `{"s":["+name","+_id"],"v":[{"$ne":null},1]}`,
+			`invalid page token, sort key "name": unsupported value type embedded document`},
+		{"operator in id", `{"s":["+name","+_id"],"v":["foo",{"$gt":{"$minKey":1}}]}`,
+			`invalid page token, sort key "_id": unsupported value type embedded document`},
+		{"array", `{"s":["+name","+_id"],"v":[["a",{"$where":"1"}],1]}`,
+			`invalid page token, sort key "name": unsupported value type array`},
+		{"null", `{"s":["+name","+_id"],"v":[null,1]}`, `invalid page token, sort key "name": unsupported value type null`},
+		{"undefined", `{"s":["+name","+_id"],"v":["foo",{"$undefined":true}]}`,
+			`invalid page token, sort key "_id": unsupported value type undefined`},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := k.Filter(base64.RawURLEncoding.EncodeToString([]byte(tt.token)))
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+
+	// regex value compared as a value with $eq, not used as a pattern
+	token := base64.RawURLEncoding.EncodeToString(
+		[]byte(`{"s":["+name","+_id"],"v":[{"$regularExpression":{"pattern":".*","options":""}},1]}`))
+	filter, err := k.Filter(token)
+	require.NoError(t, err)
+	data, err := bson.MarshalExtJSON(filter, false, false)
+	require.NoError(t, err)
+	assert.Equal(t, `{"$or":[{"name":{"$gt":{"$regularExpression":{"pattern":".*","options":""}}}},`+
+		`{"name":{"$eq":{"$regularExpression":{"pattern":".*","options":""}}},"_id":{"$gt":1}}]}`, string(data))
+}
+
+func TestKeyset_NestedKey(t *testing.T) {
+	k, err := NewKeyset("meta.rank")
+	require.NoError(t, err)
+	token, err := k.Token(bson.D{{"_id", 5}, {"meta", bson.D{{"rank", 3}}}})
+	require.NoError(t, err)
+	filter, err := k.Filter(token)
+	require.NoError(t, err)
+	data, err := bson.MarshalExtJSON(filter, false, false)
+	require.NoError(t, err)
+	assert.Equal(t, `{"$or":[{"meta.rank":{"$gt":3}},{"meta.rank":{"$eq":3},"_id":{"$gt":5}}]}`, string(data))
+}
+
+func TestKeyset_Find(t *testing.T) {
+	_, coll, teardown := MakeTestConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	// ties on created for each pair of records
+	for i := 0; i < 10; i++ {
+		_, err := coll.InsertOne(ctx, bson.M{"_id": i, "created": time.Date(2024, 1, i/2+1, 0, 0, 0, 0, time.UTC), "n": i})
+		require.NoError(t, err)
+	}
+
+	k, err := NewKeyset("-created,_id")
+	require.NoError(t, err)
+	ids, token := []int{}, ""
+	for page := 0; page < 10; page++ {
+		filter, err := k.Filter(token)
+		require.NoError(t, err)
+		cursor, err := coll.Find(ctx, filter, k.FindOptions(3))
+		require.NoError(t, err)
+		recs := []bson.Raw{}
+		require.NoError(t, cursor.All(ctx, &recs))
+		if len(recs) == 0 {
+			break
+		}
+		for _, r := range recs {
+			ids = append(ids, int(r.Lookup("_id").Int32()))
+		}
+		token, err = k.Token(recs[len(recs)-1])
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []int{8, 9, 6, 7, 4, 5, 2, 3, 0, 1}, ids)
+}
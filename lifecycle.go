@@ -0,0 +1,143 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	driver "go.mongodb.org/mongo-driver/mongo"
+)
+
+// LifecycleOptions defines shutdown time limit and signals handled by Lifecycle
+type LifecycleOptions struct {
+	Timeout time.Duration // time limit for the whole shutdown, default 30s
+	Signals []os.Signal   // signals triggering shutdown in Wait, default SIGTERM and SIGINT
+}
+
+// Lifecycle coordinates shutdown of writers and clients. On shutdown it closes all writers in parallel, flushing them,
+// waits for them within the timeout and then disconnects clients in reverse order of registration. Thread safe
+type Lifecycle struct {
+	opts LifecycleOptions
+
+	lock    sync.Mutex
+	writers []lifecycleWriter
+	closers []lifecycleCloser
+
+	once sync.Once
+	err  error
+}
+
+type lifecycleWriter struct {
+	name string
+	wr   BufferedWriter
+}
+
+type lifecycleCloser struct {
+	name  string
+	close func(ctx context.Context) error
+}
+
+// NewLifecycle makes shutdown coordinator with given options
+func NewLifecycle(opts LifecycleOptions) *Lifecycle {
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if len(opts.Signals) == 0 {
+		opts.Signals = []os.Signal{syscall.SIGTERM, os.Interrupt}
+	}
+	return &Lifecycle{opts: opts}
+}
+
+// AddWriter registers writer to close on shutdown, before any client disconnected
+func (l *Lifecycle) AddWriter(name string, wr BufferedWriter) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.writers = append(l.writers, lifecycleWriter{name: name, wr: wr})
+}
+
+// AddClient registers client to disconnect on shutdown, after all writers closed
+func (l *Lifecycle) AddClient(name string, client *driver.Client) {
+	l.AddCloser(name, client.Disconnect)
+}
+
+// AddCloser registers close function called on shutdown together with clients, i.e. Registry.Close,
+// ClientManager.Close or Connection.Close
+func (l *Lifecycle) AddCloser(name string, fn func(ctx context.Context) error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.closers = append(l.closers, lifecycleCloser{name: name, close: fn})
+}
+
+// Wait blocks till one of signals received or ctx canceled, then shuts down. Returns shutdown error
+func (l *Lifecycle) Wait(ctx context.Context) error {
+	sigCtx, stop := signal.NotifyContext(ctx, l.opts.Signals...)
+	defer stop()
+	<-sigCtx.Done()
+	return l.Shutdown(context.WithoutCancel(ctx))
+}
+
+// Shutdown closes writers in parallel, waits for them within the timeout and disconnects clients.
+// Returns all close errors joined. Shutdown done once, subsequent calls return the same error.
+// Writers and clients registered after shutdown started not closed.
+func (l *Lifecycle) Shutdown(ctx context.Context) error {
+	l.once.Do(func() {
+		l.lock.Lock()
+		writers := append([]lifecycleWriter{}, l.writers...)
+		closers := append([]lifecycleCloser{}, l.closers...)
+		l.lock.Unlock()
+
+		log.Printf("[INFO] shutdown %d writers and %d clients", len(writers), len(closers))
+		ctx, cancel := context.WithTimeout(ctx, l.opts.Timeout)
+		defer cancel()
+
+		errs := closeWriters(ctx, writers)
+		for i := len(closers) - 1; i >= 0; i-- {
+			if err := closers[i].close(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("can't disconnect %q: %w", closers[i].name, err))
+			}
+		}
+		l.err = errors.Join(errs...)
+	})
+	return l.err
+}
+
+// closeWriters closes all writers in parallel and waits for them till ctx done.
+// Writers not closed in time reported as errors, their Close calls left running.
+func closeWriters(ctx context.Context, writers []lifecycleWriter) []error {
+	type result struct {
+		idx int
+		err error
+	}
+	resCh := make(chan result, len(writers)) // buffered, late writers never block
+	for i, w := range writers {
+		go func(i int, w lifecycleWriter) {
+			resCh <- result{idx: i, err: w.wr.Close()}
+		}(i, w)
+	}
+
+	var errs []error
+	closed := make([]bool, len(writers))
+	for n := 0; n < len(writers); n++ {
+		select {
+		case r := <-resCh:
+			closed[r.idx] = true
+			if r.err != nil {
+				errs = append(errs, fmt.Errorf("can't close writer %q: %w", writers[r.idx].name, r.err))
+			}
+		case <-ctx.Done():
+			for i, w := range writers {
+				if !closed[i] {
+					errs = append(errs, fmt.Errorf("writer %q not closed: %w", w.name, ctx.Err()))
+				}
+			}
+			return errs
+		}
+	}
+	return errs
+}
@@ -0,0 +1,152 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type testWriter struct {
+	delay time.Duration
+	err   error
+	rec   *recorder
+	name  string
+}
+
+func (w *testWriter) Write(interface{}) error { return nil }
+func (w *testWriter) Flush() error            { return nil }
+func (w *testWriter) Close() error {
+	time.Sleep(w.delay)
+	w.rec.add("close " + w.name)
+	return w.err
+}
+
+type recorder struct {
+	lock  sync.Mutex
+	calls []string
+}
+
+func (r *recorder) add(call string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+func (r *recorder) list() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string{}, r.calls...)
+}
+
+func TestLifecycle_Connect(t *testing.T) {
+	mongoURL := getMongoURL(t) + "/test"
+	ctx := context.Background()
+
+	m, _, err := Connect(ctx, options.Client(), mongoURL)
+	require.NoError(t, err)
+
+	lc := NewLifecycle(LifecycleOptions{Timeout: 10 * time.Second})
+	wr := NewBufferedWriter(m, "test", "lifecycle_test", 100)
+	lc.AddWriter("events", wr)
+	lc.AddClient("main", m)
+	require.NoError(t, wr.Write(bson.M{"key": "val"}))
+	require.NoError(t, lc.Shutdown(ctx))
+
+	m2, _, err := Connect(ctx, options.Client(), mongoURL)
+	require.NoError(t, err)
+	defer m2.Disconnect(ctx)
+	coll := m2.Database("test").Collection("lifecycle_test")
+	defer coll.Drop(ctx)
+	count, err := coll.CountDocuments(ctx, bson.M{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count, "writer flushed before disconnect")
+}
+
+func TestLifecycle_Shutdown(t *testing.T) {
+	rec := &recorder{}
+	lc := NewLifecycle(LifecycleOptions{})
+	lc.AddWriter("w1", &testWriter{name: "w1", delay: 50 * time.Millisecond, rec: rec})
+	lc.AddWriter("w2", &testWriter{name: "w2", delay: 50 * time.Millisecond, rec: rec, err: errors.New("flush failed")})
+	lc.AddWriter("w3", &testWriter{name: "w3", rec: rec})
+	lc.AddCloser("c1", func(context.Context) error { rec.add("disconnect c1"); return nil })
+	lc.AddCloser("c2", func(context.Context) error { rec.add("disconnect c2"); return errors.New("timeout") })
+
+	st := time.Now()
+	err := lc.Shutdown(context.Background())
+	assert.Less(t, time.Since(st), 100*time.Millisecond, "writers closed in parallel")
+	require.Error(t, err)
+	assert.Equal(t, "can't close writer \"w2\": flush failed\ncan't disconnect \"c2\": timeout", err.Error())
+
+	calls := rec.list()
+	require.Len(t, calls, 5)
+	assert.ElementsMatch(t, []string{"close w1", "close w2", "close w3"}, calls[:3])
+	assert.Equal(t, []string{"disconnect c2", "disconnect c1"}, calls[3:], "clients after writers, in reverse order")
+
+	assert.Equal(t, err, lc.Shutdown(context.Background()), "shutdown done once")
+	assert.Len(t, rec.list(), 5)
+}
+
+func TestLifecycle_Timeout(t *testing.T) {
+	rec := &recorder{}
+	lc := NewLifecycle(LifecycleOptions{Timeout: 50 * time.Millisecond})
+	lc.AddWriter("slow", &testWriter{name: "slow", delay: time.Second, rec: rec})
+	lc.AddWriter("fast", &testWriter{name: "fast", rec: rec})
+	client, err := driver.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	require.NoError(t, err)
+	lc.AddClient("main", client)
+
+	st := time.Now()
+	err = lc.Shutdown(context.Background())
+	assert.Less(t, time.Since(st), 500*time.Millisecond)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `writer "slow" not closed: context deadline exceeded`)
+	assert.NotContains(t, err.Error(), `"fast"`)
+	assert.Equal(t, []string{"close fast"}, rec.list())
+}
+
+func TestLifecycle_Wait(t *testing.T) {
+	t.Run("context canceled", func(t *testing.T) {
+		rec := &recorder{}
+		lc := NewLifecycle(LifecycleOptions{})
+		lc.AddWriter("w1", &testWriter{name: "w1", rec: rec})
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		require.NoError(t, lc.Wait(ctx))
+		assert.Equal(t, []string{"close w1"}, rec.list())
+	})
+
+	t.Run("signal", func(t *testing.T) {
+		// keep SIGUSR1 handled to avoid default action if signal sent before Wait subscribed
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGUSR1)
+		defer signal.Stop(sigCh)
+
+		rec := &recorder{}
+		lc := NewLifecycle(LifecycleOptions{Signals: []os.Signal{syscall.SIGUSR1}})
+		lc.AddWriter("w1", &testWriter{name: "w1", rec: rec})
+		done := make(chan error)
+		go func() { done <- lc.Wait(context.Background()) }()
+
+		for {
+			require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+			select {
+			case err := <-done:
+				require.NoError(t, err)
+				assert.Equal(t, []string{"close w1"}, rec.list())
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	})
+}
@@ -0,0 +1,177 @@
+package mongo
+
+import (
+	"context"
+	"expvar"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// DefaultLatencyBuckets are upper bounds of latency histogram buckets used by NewMetrics if no buckets passed
+var DefaultLatencyBuckets = []time.Duration{time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond,
+	50 * time.Millisecond, 100 * time.Millisecond, 500 * time.Millisecond, time.Second, 5 * time.Second}
+
+// Metrics collects connection pool and command metrics with driver's PoolMonitor and CommandMonitor. Thread safe
+type Metrics struct {
+	buckets  []time.Duration
+	inFlight sync.Map // commandKey -> collection name
+
+	lock     sync.Mutex
+	pool     PoolStats
+	commands map[commandStatsKey]*CommandStats
+}
+
+// MetricsSnapshot is a copy of collected metrics
+type MetricsSnapshot struct {
+	Pool     PoolStats      `json:"pool"`
+	Commands []CommandStats `json:"commands"` // sorted by command and collection
+}
+
+// PoolStats keeps connection pool counters, summed for all servers
+type PoolStats struct {
+	Created        int64 `json:"created"`          // connections created
+	Closed         int64 `json:"closed"`           // connections closed
+	Open           int64 `json:"open"`             // connections created and not closed yet
+	CheckedOut     int64 `json:"checked_out"`      // connections checked out, total
+	CheckedIn      int64 `json:"checked_in"`       // connections returned to the pool, total
+	InUse          int64 `json:"in_use"`           // connections checked out and not returned yet
+	CheckOutFailed int64 `json:"check_out_failed"` // failed attempts to check out connection
+	Cleared        int64 `json:"cleared"`          // pool cleared, i.e. on network errors
+}
+
+// CommandStats keeps counters and latency histogram for a command on a collection
+type CommandStats struct {
+	Command       string        `json:"command"`
+	Collection    string        `json:"collection"` // empty for commands without collection, i.e. ping
+	Count         int64         `json:"count"`
+	Failed        int64         `json:"failed"`
+	TotalDuration time.Duration `json:"total_duration"`
+	MaxDuration   time.Duration `json:"max_duration"`
+	Latency       Histogram     `json:"latency"`
+}
+
+// Histogram counts values in buckets. Counts[i] is a number of values less or equal to Bounds[i] and greater
+// than Bounds[i-1], the last element of Counts keeps values greater than all bounds.
+type Histogram struct {
+	Bounds []time.Duration `json:"bounds"`
+	Counts []int64         `json:"counts"`
+}
+
+type commandStatsKey struct {
+	command, collection string
+}
+
+// NewMetrics makes metrics collector with given latency buckets, DefaultLatencyBuckets if none passed
+func NewMetrics(buckets ...time.Duration) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]time.Duration{}, buckets...)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+	return &Metrics{buckets: buckets, commands: map[commandStatsKey]*CommandStats{}}
+}
+
+// WithMetrics installs pool and command monitors collecting metrics to m
+func WithMetrics(m *Metrics) ConnectOption {
+	return func(p *connectParams) {
+		p.cmdMonitors = append(p.cmdMonitors, m.CommandMonitor())
+		p.poolMonitors = append(p.poolMonitors, m.PoolMonitor())
+	}
+}
+
+// CommandMonitor returns monitor collecting command metrics
+func (m *Metrics) CommandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, evt *event.CommandStartedEvent) {
+			m.inFlight.Store(commandKey{connID: evt.ConnectionID, requestID: evt.RequestID}, commandCollection(evt.Command))
+		},
+		Succeeded: func(_ context.Context, evt *event.CommandSucceededEvent) {
+			m.commandFinished(&evt.CommandFinishedEvent, false)
+		},
+		Failed: func(_ context.Context, evt *event.CommandFailedEvent) {
+			m.commandFinished(&evt.CommandFinishedEvent, true)
+		},
+	}
+}
+
+// PoolMonitor returns monitor collecting connection pool metrics
+func (m *Metrics) PoolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{Event: m.poolEvent}
+}
+
+// Snapshot returns copy of collected metrics
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	res := MetricsSnapshot{Pool: m.pool, Commands: make([]CommandStats, 0, len(m.commands))}
+	for _, cs := range m.commands {
+		c := *cs
+		c.Latency = Histogram{Bounds: m.buckets, Counts: append([]int64{}, cs.Latency.Counts...)}
+		res.Commands = append(res.Commands, c)
+	}
+	sort.Slice(res.Commands, func(i, j int) bool {
+		if res.Commands[i].Command != res.Commands[j].Command {
+			return res.Commands[i].Command < res.Commands[j].Command
+		}
+		return res.Commands[i].Collection < res.Commands[j].Collection
+	})
+	return res
+}
+
+// Publish exposes metrics snapshot with expvar under given name. Like expvar.Publish it panics if the name already used
+func (m *Metrics) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} { return m.Snapshot() }))
+}
+
+func (m *Metrics) commandFinished(evt *event.CommandFinishedEvent, failed bool) {
+	coll := ""
+	if v, ok := m.inFlight.LoadAndDelete(commandKey{connID: evt.ConnectionID, requestID: evt.RequestID}); ok {
+		coll = v.(string)
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	key := commandStatsKey{command: evt.CommandName, collection: coll}
+	cs, ok := m.commands[key]
+	if !ok {
+		cs = &CommandStats{Command: evt.CommandName, Collection: coll, Latency: Histogram{Counts: make([]int64, len(m.buckets)+1)}}
+		m.commands[key] = cs
+	}
+	cs.Count++
+	if failed {
+		cs.Failed++
+	}
+	cs.TotalDuration += evt.Duration
+	if evt.Duration > cs.MaxDuration {
+		cs.MaxDuration = evt.Duration
+	}
+	idx := sort.Search(len(m.buckets), func(i int) bool { return evt.Duration <= m.buckets[i] })
+	cs.Latency.Counts[idx]++
+}
+
+func (m *Metrics) poolEvent(evt *event.PoolEvent) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	switch evt.Type {
+	case event.ConnectionCreated:
+		m.pool.Created++
+		m.pool.Open++
+	case event.ConnectionClosed:
+		m.pool.Closed++
+		m.pool.Open--
+	case event.GetSucceeded:
+		m.pool.CheckedOut++
+		m.pool.InUse++
+	case event.ConnectionReturned:
+		m.pool.CheckedIn++
+		m.pool.InUse--
+	case event.GetFailed:
+		m.pool.CheckOutFailed++
+	case event.PoolCleared:
+		m.pool.Cleared++
+	}
+}
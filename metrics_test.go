@@ -0,0 +1,118 @@
+package mongo
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMetrics_Connect(t *testing.T) {
+	mongoURL := getMongoURL(t) + "/test"
+	ctx := context.Background()
+
+	metrics := NewMetrics()
+	m, _, err := ConnectWithOptions(ctx, options.Client(), mongoURL, nil, WithMetrics(metrics))
+	require.NoError(t, err)
+
+	coll := m.Database("test").Collection("metrics_test")
+	_, err = coll.InsertOne(ctx, bson.M{"key": "val"})
+	require.NoError(t, err)
+	require.NoError(t, coll.FindOne(ctx, bson.M{"key": "val"}).Err())
+	require.NoError(t, coll.Drop(ctx))
+
+	snap := metrics.Snapshot()
+	t.Logf("%+v", snap)
+	assert.Greater(t, snap.Pool.Created, int64(0))
+	assert.Greater(t, snap.Pool.CheckedOut, int64(0))
+	assert.Equal(t, int64(0), snap.Pool.InUse)
+	found := map[string]bool{}
+	for _, c := range snap.Commands {
+		found[c.Command+":"+c.Collection] = true
+	}
+	assert.True(t, found["insert:metrics_test"])
+	assert.True(t, found["find:metrics_test"])
+	assert.True(t, found["ping:"])
+
+	require.NoError(t, m.Disconnect(ctx))
+	assert.Equal(t, int64(0), metrics.Snapshot().Pool.Open)
+}
+
+func TestMetrics_Commands(t *testing.T) {
+	metrics := NewMetrics(100*time.Millisecond, 10*time.Millisecond)
+	mon := metrics.CommandMonitor()
+
+	run := func(reqID int64, cmd bson.D, dur time.Duration, failed bool) {
+		raw, err := bson.Marshal(cmd)
+		require.NoError(t, err)
+		mon.Started(context.Background(), &event.CommandStartedEvent{Command: raw, CommandName: cmd[0].Key, RequestID: reqID, ConnectionID: "c1"})
+		fin := event.CommandFinishedEvent{CommandName: cmd[0].Key, RequestID: reqID, ConnectionID: "c1", Duration: dur}
+		if failed {
+			mon.Failed(context.Background(), &event.CommandFailedEvent{CommandFinishedEvent: fin})
+			return
+		}
+		mon.Succeeded(context.Background(), &event.CommandSucceededEvent{CommandFinishedEvent: fin})
+	}
+
+	run(1, bson.D{{"find", "coll1"}}, 5*time.Millisecond, false)
+	run(2, bson.D{{"find", "coll1"}}, 50*time.Millisecond, false)
+	run(3, bson.D{{"find", "coll1"}}, time.Second, true)
+	run(4, bson.D{{"find", "coll2"}}, 10*time.Millisecond, false)
+	run(5, bson.D{{"ping", 1}}, time.Millisecond, false)
+
+	bounds := []time.Duration{10 * time.Millisecond, 100 * time.Millisecond}
+	assert.Equal(t, []CommandStats{
+		{Command: "find", Collection: "coll1", Count: 3, Failed: 1, TotalDuration: 1055 * time.Millisecond, MaxDuration: time.Second,
+			Latency: Histogram{Bounds: bounds, Counts: []int64{1, 1, 1}}},
+		{Command: "find", Collection: "coll2", Count: 1, TotalDuration: 10 * time.Millisecond, MaxDuration: 10 * time.Millisecond,
+			Latency: Histogram{Bounds: bounds, Counts: []int64{1, 0, 0}}},
+		{Command: "ping", Count: 1, TotalDuration: time.Millisecond, MaxDuration: time.Millisecond,
+			Latency: Histogram{Bounds: bounds, Counts: []int64{1, 0, 0}}},
+	}, metrics.Snapshot().Commands)
+
+	snap := metrics.Snapshot()
+	snap.Commands[0].Latency.Counts[0] = 100
+	assert.Equal(t, int64(1), metrics.Snapshot().Commands[0].Latency.Counts[0], "snapshot is a copy")
+}
+
+func TestMetrics_Pool(t *testing.T) {
+	metrics := NewMetrics()
+	mon := chainPoolMonitors(nil, metrics.PoolMonitor(), &event.PoolMonitor{})
+	for _, tp := range []string{event.PoolCreated, event.ConnectionCreated, event.ConnectionCreated, event.ConnectionReady,
+		event.GetStarted, event.GetSucceeded, event.GetStarted, event.GetSucceeded, event.ConnectionReturned,
+		event.GetStarted, event.GetFailed, event.PoolCleared, event.ConnectionClosed} {
+		mon.Event(&event.PoolEvent{Type: tp})
+	}
+	assert.Equal(t, PoolStats{Created: 2, Closed: 1, Open: 1, CheckedOut: 2, CheckedIn: 1, InUse: 1, CheckOutFailed: 1, Cleared: 1},
+		metrics.Snapshot().Pool)
+}
+
+func TestMetrics_Publish(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.PoolMonitor().Event(&event.PoolEvent{Type: event.ConnectionCreated})
+	metrics.Publish("mongo_metrics_test")
+
+	v := expvar.Get("mongo_metrics_test")
+	require.NotNil(t, v)
+	snap := MetricsSnapshot{}
+	require.NoError(t, json.Unmarshal([]byte(v.String()), &snap))
+	assert.Equal(t, int64(1), snap.Pool.Created)
+	assert.Equal(t, []CommandStats{}, snap.Commands)
+}
+
+func TestChainPoolMonitors(t *testing.T) {
+	var calls []string
+	m1 := &event.PoolMonitor{Event: func(evt *event.PoolEvent) { calls = append(calls, "m1 "+evt.Type) }}
+	m2 := &event.PoolMonitor{Event: func(evt *event.PoolEvent) { calls = append(calls, "m2 "+evt.Type) }}
+
+	assert.Same(t, m1, chainPoolMonitors(m1, nil, &event.PoolMonitor{}))
+	chainPoolMonitors(m1, m2).Event(&event.PoolEvent{Type: event.ConnectionCreated})
+	assert.Equal(t, []string{"m1 ConnectionCreated", "m2 ConnectionCreated"}, calls)
+}
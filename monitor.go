@@ -0,0 +1,151 @@
+package mongo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// commandKey identifies command in flight, to match started and finished events
+type commandKey struct {
+	connID    string
+	requestID int64
+}
+
+// chainCommandMonitors combines command monitors into one, calling them in order. Nil monitors skipped
+func chainCommandMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	res := []*event.CommandMonitor{}
+	for _, m := range monitors {
+		if m != nil {
+			res = append(res, m)
+		}
+	}
+	if len(res) == 1 {
+		return res[0]
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			for _, m := range res {
+				if m.Started != nil {
+					m.Started(ctx, evt)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			for _, m := range res {
+				if m.Succeeded != nil {
+					m.Succeeded(ctx, evt)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			for _, m := range res {
+				if m.Failed != nil {
+					m.Failed(ctx, evt)
+				}
+			}
+		},
+	}
+}
+
+// chainPoolMonitors combines pool monitors into one, calling them in order. Nil monitors skipped
+func chainPoolMonitors(monitors ...*event.PoolMonitor) *event.PoolMonitor {
+	res := []*event.PoolMonitor{}
+	for _, m := range monitors {
+		if m != nil && m.Event != nil {
+			res = append(res, m)
+		}
+	}
+	if len(res) == 1 {
+		return res[0]
+	}
+
+	return &event.PoolMonitor{
+		Event: func(evt *event.PoolEvent) {
+			for _, m := range res {
+				m.Event(evt)
+			}
+		},
+	}
+}
+
+// chainServerMonitors combines server monitors into one, calling them in order. Nil monitors skipped
+func chainServerMonitors(monitors ...*event.ServerMonitor) *event.ServerMonitor {
+	res := []*event.ServerMonitor{}
+	for _, m := range monitors {
+		if m != nil {
+			res = append(res, m)
+		}
+	}
+	if len(res) == 1 {
+		return res[0]
+	}
+
+	return &event.ServerMonitor{
+		ServerDescriptionChanged: chainServerEvent(res, func(m *event.ServerMonitor) func(*event.ServerDescriptionChangedEvent) {
+			return m.ServerDescriptionChanged
+		}),
+		ServerOpening: chainServerEvent(res, func(m *event.ServerMonitor) func(*event.ServerOpeningEvent) {
+			return m.ServerOpening
+		}),
+		ServerClosed: chainServerEvent(res, func(m *event.ServerMonitor) func(*event.ServerClosedEvent) {
+			return m.ServerClosed
+		}),
+		TopologyDescriptionChanged: chainServerEvent(res, func(m *event.ServerMonitor) func(*event.TopologyDescriptionChangedEvent) {
+			return m.TopologyDescriptionChanged
+		}),
+		TopologyOpening: chainServerEvent(res, func(m *event.ServerMonitor) func(*event.TopologyOpeningEvent) {
+			return m.TopologyOpening
+		}),
+		TopologyClosed: chainServerEvent(res, func(m *event.ServerMonitor) func(*event.TopologyClosedEvent) {
+			return m.TopologyClosed
+		}),
+		ServerHeartbeatStarted: chainServerEvent(res, func(m *event.ServerMonitor) func(*event.ServerHeartbeatStartedEvent) {
+			return m.ServerHeartbeatStarted
+		}),
+		ServerHeartbeatSucceeded: chainServerEvent(res, func(m *event.ServerMonitor) func(*event.ServerHeartbeatSucceededEvent) {
+			return m.ServerHeartbeatSucceeded
+		}),
+		ServerHeartbeatFailed: chainServerEvent(res, func(m *event.ServerMonitor) func(*event.ServerHeartbeatFailedEvent) {
+			return m.ServerHeartbeatFailed
+		}),
+	}
+}
+
+// chainServerEvent combines one callback of server monitors, picked by get, calling them in order.
+// Returns nil if no monitor sets the callback
+func chainServerEvent[E any](monitors []*event.ServerMonitor, get func(m *event.ServerMonitor) func(E)) func(E) {
+	fns := []func(E){}
+	for _, m := range monitors {
+		if fn := get(m); fn != nil {
+			fns = append(fns, fn)
+		}
+	}
+	switch len(fns) {
+	case 0:
+		return nil
+	case 1:
+		return fns[0]
+	}
+	return func(evt E) {
+		for _, fn := range fns {
+			fn(evt)
+		}
+	}
+}
+
+// commandCollection returns collection name from command body, i.e. "events" for {"find": "events", ...}.
+// Returns empty string for commands without collection, i.e. {"ping": 1}
+func commandCollection(cmd bson.Raw) string {
+	elem, err := cmd.IndexErr(0)
+	if err != nil {
+		return ""
+	}
+	if val := elem.Value(); val.Type == bsontype.String {
+		return val.StringValue()
+	}
+	return ""
+}
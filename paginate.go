@@ -0,0 +1,145 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Page is a single page of records with pagination metadata. Pages numbered from 1
+type Page[T any] struct {
+	Items   []T   `json:"items"`
+	Page    int64 `json:"page"`
+	PerPage int64 `json:"per_page"`
+	Total   int64 `json:"total"`
+	Pages   int64 `json:"pages"`
+	Next    int64 `json:"next,omitempty"` // next page number, 0 if this page is the last one
+	Prev    int64 `json:"prev,omitempty"` // previous page number, the last one for pages beyond it, 0 for the first page
+}
+
+// PaginateOptions defines counting and skip limit of PaginateWithOptions
+type PaginateOptions struct {
+	EstimatedCount bool  // use EstimatedDocumentCount for empty filter, fast but can be inaccurate
+	MaxSkip        int64 // max number of records to skip, error for pages beyond it, no limit if 0
+}
+
+// Paginate returns page of records matching filter, sorted with PrepSort. Runs find and count concurrently.
+func Paginate[T any](ctx context.Context, coll *driver.Collection, filter interface{}, page, perPage int64,
+	sort ...string) (*Page[T], error) {
+	return PaginateWithOptions[T](ctx, coll, filter, page, perPage, PaginateOptions{}, sort...)
+}
+
+// PaginateWithOptions is the same as Paginate, but accepts PaginateOptions
+func PaginateWithOptions[T any](ctx context.Context, coll *driver.Collection, filter interface{}, page, perPage int64,
+	opts PaginateOptions, sort ...string) (*Page[T], error) {
+	skip, err := paginateSkip(page, perPage, opts.MaxSkip)
+	if err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		filter = bson.D{}
+	}
+	estimate, err := isEmptyFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	fo := options.Find().SetSkip(skip).SetLimit(perPage)
+	if len(sort) > 0 {
+		fo.SetSort(PrepSort(sort...))
+	}
+	res := &Page[T]{Items: []T{}, Page: page, PerPage: perPage}
+	if res.Total, err = findAndCount(ctx, coll, filter, fo, estimate && opts.EstimatedCount, &res.Items); err != nil {
+		return nil, err
+	}
+	res.setPages()
+	return res, nil
+}
+
+// paginateSkip returns number of records to skip for the page, rejecting invalid pages and skips overflowing
+// int64 or maxSkip, if set
+func paginateSkip(page, perPage, maxSkip int64) (int64, error) {
+	if page < 1 || perPage < 1 {
+		return 0, fmt.Errorf("invalid page %d or per page %d, should be positive", page, perPage)
+	}
+	if page-1 > math.MaxInt64/perPage {
+		return 0, fmt.Errorf("page %d is too far, number of records to skip overflows with per page %d", page, perPage)
+	}
+	skip := (page - 1) * perPage
+	if maxSkip > 0 && skip > maxSkip {
+		return 0, fmt.Errorf("page %d is too far, can't skip more than %d records", page, maxSkip)
+	}
+	return skip, nil
+}
+
+// findAndCount runs find to items and count concurrently, returns total number of records matching filter.
+// Both canceled if one of them fails
+func findAndCount[T any](ctx context.Context, coll *driver.Collection, filter interface{}, fo *options.FindOptions,
+	estimate bool, items *[]T) (total int64, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var findErr, countErr error
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		cursor, err := coll.Find(ctx, filter, fo)
+		if err != nil {
+			findErr = fmt.Errorf("can't find records: %w", err)
+			cancel()
+			return
+		}
+		if err = cursor.All(ctx, items); err != nil {
+			findErr = fmt.Errorf("can't read records: %w", err)
+			cancel()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		var err error
+		if estimate {
+			total, err = coll.EstimatedDocumentCount(ctx)
+		} else {
+			total, err = coll.CountDocuments(ctx, filter)
+		}
+		if err != nil {
+			countErr = fmt.Errorf("can't count records: %w", err)
+			cancel()
+		}
+	}()
+	wg.Wait()
+	if findErr != nil || countErr != nil {
+		return 0, errors.Join(findErr, countErr)
+	}
+	return total, nil
+}
+
+// setPages sets number of pages, next and previous pages from total
+func (p *Page[T]) setPages() {
+	p.Pages = p.Total / p.PerPage
+	if p.Total%p.PerPage > 0 {
+		p.Pages++
+	}
+	if p.Page < p.Pages {
+		p.Next = p.Page + 1
+	}
+	if p.Page > 1 {
+		p.Prev = min(p.Page-1, max(p.Pages, 1)) // pages beyond the last one point back to the last page
+	}
+}
+
+// isEmptyFilter checks if filter has no conditions
+func isEmptyFilter(filter interface{}) (bool, error) {
+	data, err := bson.Marshal(filter)
+	if err != nil {
+		return false, fmt.Errorf("can't marshal filter: %w", err)
+	}
+	return len(data) <= 5, nil // empty document is 5 bytes, int32 length and terminating zero
+}
@@ -0,0 +1,111 @@
+package mongo
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestPaginate(t *testing.T) {
+	_, coll, teardown := MakeTestConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	type rec struct {
+		ID  int    `bson:"_id"`
+		Grp string `bson:"grp"`
+	}
+	for i := 0; i < 25; i++ {
+		grp := "a"
+		if i%5 == 0 {
+			grp = "b"
+		}
+		_, err := coll.InsertOne(ctx, rec{ID: i, Grp: grp})
+		require.NoError(t, err)
+	}
+
+	p, err := Paginate[rec](ctx, coll, nil, 1, 10, "-_id")
+	require.NoError(t, err)
+	assert.Len(t, p.Items, 10)
+	assert.Equal(t, 24, p.Items[0].ID)
+	assert.Equal(t, Page[rec]{Items: p.Items, Page: 1, PerPage: 10, Total: 25, Pages: 3, Next: 2}, *p)
+
+	p, err = Paginate[rec](ctx, coll, bson.M{}, 3, 10, "_id")
+	require.NoError(t, err)
+	assert.Equal(t, []rec{{20, "b"}, {21, "a"}, {22, "a"}, {23, "a"}, {24, "a"}}, p.Items)
+	assert.Equal(t, int64(0), p.Next)
+	assert.Equal(t, int64(2), p.Prev)
+
+	p, err = Paginate[rec](ctx, coll, bson.M{"grp": "b"}, 2, 3, "_id")
+	require.NoError(t, err)
+	assert.Equal(t, []rec{{15, "b"}, {20, "b"}}, p.Items)
+	assert.Equal(t, Page[rec]{Items: p.Items, Page: 2, PerPage: 3, Total: 5, Pages: 2, Prev: 1}, *p)
+
+	p, err = Paginate[rec](ctx, coll, bson.M{"grp": "b"}, 5, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []rec{}, p.Items, "beyond the last page")
+	assert.Equal(t, int64(2), p.Prev, "prev is the last page")
+	assert.Equal(t, int64(0), p.Next)
+
+	p, err = Paginate[rec](ctx, coll, bson.M{"grp": "c"}, 3, 3)
+	require.NoError(t, err)
+	assert.Equal(t, Page[rec]{Items: []rec{}, Page: 3, PerPage: 3, Prev: 1}, *p, "no records, prev is the first page")
+
+	p, err = PaginateWithOptions[rec](ctx, coll, bson.D{}, 2, 10, PaginateOptions{EstimatedCount: true, MaxSkip: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(25), p.Total)
+	_, err = PaginateWithOptions[rec](ctx, coll, nil, 3, 10, PaginateOptions{MaxSkip: 10})
+	assert.EqualError(t, err, "page 3 is too far, can't skip more than 10 records")
+}
+
+func TestPaginate_Errors(t *testing.T) {
+	_, err := Paginate[bson.M](context.Background(), nil, nil, 0, 10)
+	assert.EqualError(t, err, "invalid page 0 or per page 10, should be positive")
+	_, err = Paginate[bson.M](context.Background(), nil, nil, 1, 0)
+	assert.EqualError(t, err, "invalid page 1 or per page 0, should be positive")
+	_, err = PaginateWithOptions[bson.M](context.Background(), nil, nil, 101, 10, PaginateOptions{MaxSkip: 999})
+	assert.EqualError(t, err, "page 101 is too far, can't skip more than 999 records")
+	_, err = Paginate[bson.M](context.Background(), nil, nil, math.MaxInt64/10+2, 10)
+	assert.EqualError(t, err, "page 922337203685477582 is too far, number of records to skip overflows with per page 10")
+	_, err = Paginate[bson.M](context.Background(), nil, nil, 3, math.MaxInt64)
+	assert.EqualError(t, err, "page 3 is too far, number of records to skip overflows with per page 9223372036854775807")
+	_, err = Paginate[bson.M](context.Background(), nil, "bad filter", 1, 10)
+	assert.ErrorContains(t, err, "can't marshal filter")
+}
+
+func TestIsEmptyFilter(t *testing.T) {
+	for _, f := range []interface{}{bson.D{}, bson.M{}, map[string]interface{}{}, struct{}{}} {
+		empty, err := isEmptyFilter(f)
+		require.NoError(t, err)
+		assert.True(t, empty, "%#v", f)
+	}
+	for _, f := range []interface{}{bson.D{{"a", 1}}, bson.M{"a": 1}, struct{ A int }{}} {
+		empty, err := isEmptyFilter(f)
+		require.NoError(t, err)
+		assert.False(t, empty, "%#v", f)
+	}
+}
+
+func TestPage_SetPages(t *testing.T) {
+	tbl := []struct {
+		page, perPage, total int64
+		pages, next, prev    int64
+	}{
+		{1, 10, 0, 0, 0, 0},
+		{1, 10, 25, 3, 2, 0},
+		{2, 10, 25, 3, 3, 1},
+		{3, 10, 30, 3, 0, 2},
+		{5, 10, 25, 3, 0, 3},
+		{5, 10, 0, 0, 0, 1},
+		{2, math.MaxInt64, math.MaxInt64, 1, 0, 1},
+	}
+	for _, tt := range tbl {
+		p := Page[bson.M]{Page: tt.page, PerPage: tt.perPage, Total: tt.total}
+		p.setPages()
+		assert.Equal(t, []int64{tt.pages, tt.next, tt.prev}, []int64{p.Pages, p.Next, p.Prev}, "%+v", tt)
+	}
+}
@@ -0,0 +1,216 @@
+package mongo
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	reProjSlice     = regexp.MustCompile(`^([^\[]+)\[(-?\d+)(?::(\d+))?]$`)
+	reProjElemMatch = regexp.MustCompile(`^([^{]+)\{(.*)}$`)
+)
+
+// PrepProjection prepares projection for mongo driver and returns bson.D, the same syntax as PrepSort.
+// Input string provided as [+|-]field1,[+|-]field2,... + or no prefix means inclusion, - means exclusion.
+// Shorthands for array fields:
+//   - field[n] makes {$slice: n}, first n elements, or last n if negative
+//   - field[skip:limit] makes {$slice: [skip, limit]}
+//   - field{filter} makes {$elemMatch: filter}, filter in PrepFilter syntax, i.e. items{status=active,qty>5}
+//
+// Mixing inclusion and exclusion rejected, except for _id. Duplicated, empty, colliding (i.e. a and a.b)
+// fields and fields with $ rejected too.
+func PrepProjection(fields ...string) (bson.D, error) {
+	return prepProjection(nil, fields...)
+}
+
+// PrepProjectionFor is the same as PrepProjection, but also checks fields against bson fields of v,
+// a struct or pointer to struct. Nested fields checked through nested structs, slices and pointers,
+// fields of maps and interfaces not checked.
+func PrepProjectionFor(v interface{}, fields ...string) (bson.D, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("projection type should be a struct, got %T", v)
+	}
+	return prepProjection(t, fields...)
+}
+
+func prepProjection(typ reflect.Type, fields ...string) (bson.D, error) {
+	res := bson.D{}
+	include, exclude := "", "" // first included and excluded fields, to report mix
+	for _, s := range fields {
+		items, err := splitFilter(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid projection %q: %w", s, err)
+		}
+		for _, f := range items {
+			f = strings.TrimSpace(f)
+			if f == "" {
+				continue
+			}
+			field, val, err := parseProjectionField(f)
+			if err == nil {
+				err = checkProjectionField(res, field)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid projection %q: %w", f, err)
+			}
+			if typ != nil && field != "_id" && !structHasPath(typ, strings.Split(field, ".")) {
+				return nil, fmt.Errorf("invalid projection %q: no field %q in %s", f, field, typ)
+			}
+			if err := checkProjectionMix(&include, &exclude, field, val); err != nil {
+				return nil, err
+			}
+			res = append(res, bson.E{Key: field, Value: val})
+		}
+	}
+	return res, nil
+}
+
+// checkProjectionMix remembers the first included and excluded fields and rejects their mix, _id and
+// shorthands not counted
+func checkProjectionMix(include, exclude *string, field string, val interface{}) error {
+	v, ok := val.(int)
+	if !ok || field == "_id" {
+		return nil
+	}
+	if v == 1 && *include == "" {
+		*include = field
+	}
+	if v == 0 && *exclude == "" {
+		*exclude = field
+	}
+	if *include != "" && *exclude != "" {
+		return fmt.Errorf("can't mix inclusion of %q and exclusion of %q in projection", *include, *exclude)
+	}
+	return nil
+}
+
+// parseProjectionField parses single projection field with optional prefix or shorthand
+func parseProjectionField(f string) (field string, val interface{}, err error) {
+	if m := reProjSlice.FindStringSubmatch(f); m != nil {
+		val, err := projectionSlice(m[2], m[3])
+		return strings.TrimSpace(m[1]), val, err
+	}
+	if m := reProjElemMatch.FindStringSubmatch(f); m != nil {
+		val, err := projectionElemMatch(m[2])
+		return strings.TrimSpace(m[1]), val, err
+	}
+
+	switch f[0] {
+	case '-':
+		return strings.TrimSpace(f[1:]), 0, nil
+	case '+':
+		return strings.TrimSpace(f[1:]), 1, nil
+	default:
+		return f, 1, nil
+	}
+}
+
+// projectionSlice makes $slice for field[n] or field[skip:limit] shorthand, limit is empty for field[n]
+func projectionSlice(n, limit string) (bson.D, error) {
+	skip, err := strconv.Atoi(n)
+	if err != nil {
+		return nil, fmt.Errorf("invalid slice: %w", err)
+	}
+	if limit == "" {
+		return bson.D{{Key: "$slice", Value: skip}}, nil
+	}
+	l, err := strconv.Atoi(limit)
+	if err != nil || l == 0 {
+		return nil, errors.New("invalid slice limit, should be positive")
+	}
+	return bson.D{{Key: "$slice", Value: bson.A{skip, l}}}, nil
+}
+
+// projectionElemMatch makes $elemMatch for field{filter} shorthand
+func projectionElemMatch(expr string) (bson.D, error) {
+	filter, err := PrepFilterAny(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(filter) == 0 {
+		return nil, errors.New("empty elemMatch filter")
+	}
+	return bson.D{{Key: "$elemMatch", Value: filter}}, nil
+}
+
+// checkProjectionField rejects empty fields, fields with $, duplicates and path collisions with already added fields
+func checkProjectionField(added bson.D, field string) error {
+	if field == "" {
+		return errors.New("empty field")
+	}
+	if strings.Contains(field, "$") {
+		return fmt.Errorf("field %q can't contain $", field)
+	}
+	if strings.ContainsAny(field, " \t[]{}") || strings.HasPrefix(field, "-") || strings.HasPrefix(field, "+") ||
+		strings.HasPrefix(field, ".") || strings.HasSuffix(field, ".") || strings.Contains(field, "..") {
+		return fmt.Errorf("invalid field %q", field)
+	}
+	for _, e := range added {
+		switch {
+		case e.Key == field:
+			return fmt.Errorf("duplicate field %q", field)
+		case strings.HasPrefix(field, e.Key+"."), strings.HasPrefix(e.Key, field+"."):
+			return fmt.Errorf("field %q collides with %q", field, e.Key)
+		}
+	}
+	return nil
+}
+
+// structHasPath checks if bson path exists in type t. Fields named by bson tag or lowercased field name,
+// as bson encoder does, inline structs flattened. Paths under maps and interfaces always accepted.
+func structHasPath(t reflect.Type, path []string) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if len(path) == 0 {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Map, reflect.Interface:
+		return true
+	case reflect.Struct:
+	default:
+		return false
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, inline, ok := bsonFieldName(sf)
+		if !ok {
+			continue
+		}
+		if inline && structHasPath(sf.Type, path) || name == path[0] && structHasPath(sf.Type, path[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// bsonFieldName returns bson name of struct field and inline flag, ok is false for fields skipped by bson encoder
+func bsonFieldName(sf reflect.StructField) (name string, inline, ok bool) {
+	if !sf.IsExported() && !(sf.Anonymous && sf.Type.Kind() == reflect.Struct) { // embedded structs can be inlined
+		return "", false, false
+	}
+	tag := sf.Tag.Get("bson")
+	if tag == "-" {
+		return "", false, false
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if strings.Contains(","+opts+",", ",inline,") {
+		return "", true, true
+	}
+	if name == "" {
+		name = strings.ToLower(sf.Name)
+	}
+	return name, false, true
+}
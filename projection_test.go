@@ -0,0 +1,129 @@
+package mongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestPrepProjection(t *testing.T) {
+	tbl := []struct {
+		name string
+		inp  []string
+		out  bson.D
+	}{
+		{"empty", nil, bson.D{}},
+		{"inclusion", []string{"name,+meta.created", " age "}, bson.D{{"name", 1}, {"meta.created", 1}, {"age", 1}}},
+		{"exclusion with id", []string{"-password", "-secret", "+_id"}, bson.D{{"password", 0}, {"secret", 0}, {"_id", 1}}},
+		{"inclusion without id", []string{"name,-_id"}, bson.D{{"name", 1}, {"_id", 0}}},
+		{"slice", []string{"name,comments[5],log[-3],items[10:5],last[-5:2]"}, bson.D{{"name", 1},
+			{"comments", bson.D{{"$slice", 5}}}, {"log", bson.D{{"$slice", -3}}}, {"items", bson.D{{"$slice", bson.A{10, 5}}}},
+			{"last", bson.D{{"$slice", bson.A{-5, 2}}}}}},
+		{"slice with exclusion", []string{"-password,comments[5]"}, bson.D{{"password", 0}, {"comments", bson.D{{"$slice", 5}}}}},
+		{"elemMatch", []string{"name,items{status=active,qty>5}"}, bson.D{{"name", 1},
+			{"items", bson.D{{"$elemMatch", bson.D{{"status", "active"}, {"qty", bson.D{{"$gt", int64(5)}}}}}}}}},
+		{"elemMatch with bracket in regex", []string{"items{name~/}/},age"}, bson.D{
+			{"items", bson.D{{"$elemMatch", bson.D{{"name", bson.D{{"$regex", primitive.Regex{Pattern: "}"}}}}}}}}, {"age", 1}}},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := PrepProjection(tt.inp...)
+			require.NoError(t, err)
+			assert.Equal(t, tt.out, out)
+		})
+	}
+}
+
+func TestPrepProjection_Errors(t *testing.T) {
+	tbl := []struct {
+		inp string
+		err string
+	}{
+		{"name,-password", `can't mix inclusion of "name" and exclusion of "password" in projection`},
+		{"-password,+name", `can't mix inclusion of "name" and exclusion of "password" in projection`},
+		{"-", `invalid projection "-": empty field`},
+		{"name,name", `invalid projection "name": duplicate field "name"`},
+		{"meta,meta.created", `invalid projection "meta.created": field "meta.created" collides with "meta"`},
+		{"meta.created,meta", `invalid projection "meta": field "meta" collides with "meta.created"`},
+		{"$where", `invalid projection "$where": field "$where" can't contain $`},
+		{"a.$", `invalid projection "a.$": field "a.$" can't contain $`},
+		{"-items[5]", `invalid projection "-items[5]": invalid field "-items"`},
+		{"items[5:0]", `invalid projection "items[5:0]": invalid slice limit, should be positive`},
+		{"items[x]", `invalid projection "items[x]": invalid field "items[x]"`},
+		{"items{}", `invalid projection "items{}": empty elemMatch filter`},
+		{"items{$where=1}", `invalid projection "items{$where=1}": invalid filter condition "$where=1": field "$where" can't contain $`},
+		{"name,items[5", `invalid projection "name,items[5": unclosed bracket, "]" expected`},
+		{"name,items}", `invalid projection "name,items}": unbalanced "}" at 10`},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.inp, func(t *testing.T) {
+			_, err := PrepProjection(tt.inp)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestPrepProjectionFor(t *testing.T) {
+	type meta struct {
+		Created time.Time `bson:"created"`
+		Tags    []string
+	}
+	type base struct {
+		Owner string `bson:"owner"`
+	}
+	type record struct {
+		base     `bson:",inline"`
+		Name     string                 `bson:"name"`
+		Password string                 `bson:"password,omitempty"`
+		Skipped  string                 `bson:"-"`
+		Meta     *meta                  `bson:"meta"`
+		Items    []meta                 `bson:"items"`
+		Attrs    map[string]interface{} `bson:"attrs"`
+		Counter  int
+	}
+
+	out, err := PrepProjectionFor(record{}, "name,meta.created,meta.tags,items.created,attrs.any.path,counter,_id,owner")
+	require.NoError(t, err)
+	assert.Len(t, out, 8)
+
+	_, err = PrepProjectionFor(&record{}, "-password,items[2]")
+	require.NoError(t, err)
+
+	_, err = PrepProjectionFor(record{}, "nmae")
+	assert.EqualError(t, err, `invalid projection "nmae": no field "nmae" in mongo.record`)
+	_, err = PrepProjectionFor(record{}, "meta.updated")
+	assert.EqualError(t, err, `invalid projection "meta.updated": no field "meta.updated" in mongo.record`)
+	_, err = PrepProjectionFor(record{}, "name.first")
+	assert.EqualError(t, err, `invalid projection "name.first": no field "name.first" in mongo.record`)
+	_, err = PrepProjectionFor(record{}, "skipped")
+	assert.EqualError(t, err, `invalid projection "skipped": no field "skipped" in mongo.record`)
+	_, err = PrepProjectionFor("str", "name")
+	assert.EqualError(t, err, "projection type should be a struct, got string")
+	_, err = PrepProjectionFor(nil, "name")
+	assert.EqualError(t, err, "projection type should be a struct, got <nil>")
+}
+
+func TestPrepProjection_Find(t *testing.T) {
+	_, coll, teardown := MakeTestConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	_, err := coll.InsertOne(ctx, bson.M{"name": "foo", "password": "secret", "comments": []int{1, 2, 3, 4},
+		"items": []bson.M{{"status": "new", "qty": 1}, {"status": "active", "qty": 10}}})
+	require.NoError(t, err)
+
+	proj, err := PrepProjection("name,comments[-2],items{status=active},-_id")
+	require.NoError(t, err)
+	res := bson.M{}
+	require.NoError(t, coll.FindOne(ctx, bson.M{}, options.FindOne().SetProjection(proj)).Decode(&res))
+	assert.Equal(t, bson.M{"name": "foo", "comments": bson.A{int32(3), int32(4)},
+		"items": bson.A{bson.M{"status": "active", "qty": int32(10)}}}, res)
+}
@@ -0,0 +1,168 @@
+package mongo
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// query params used by PrepFind
+const (
+	QueryFilter = "filter"
+	QuerySort   = "sort"
+	QueryLimit  = "limit"
+	QuerySkip   = "skip"
+	QueryFields = "fields"
+)
+
+// QueryOptions defines limits and allowed fields for PrepFind. Empty whitelist allows no fields,
+// AllowAll accepts fields missing in whitelists as is, i.e. for trusted input.
+type QueryOptions struct {
+	DefaultLimit     int64             // limit used if not set in query, no limit if 0
+	MaxLimit         int64             // larger limits reduced to MaxLimit, no max if 0
+	DefaultSort      string            // sort used if not set in query, i.e. "-created", checked against SortFields
+	SortFields       map[string]string // allowed sort fields mapped to stored paths, see PrepSortStrict
+	FilterFields     []string          // allowed filter fields, see PrepFilter, also used for elemMatch projections
+	ProjectionFields []string          // allowed projection fields, _id always allowed
+	AllowAll         bool              // accept any field, fields with $ still rejected
+}
+
+// QueryError reports invalid query param. All errors returned by PrepFind are *QueryError,
+// to respond with 400 Bad Request.
+type QueryError struct {
+	Param string
+	Err   error
+}
+
+// Error returns error message with param name
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid %s: %v", e.Param, e.Err)
+}
+
+// Unwrap returns underlying error
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// PrepFind makes filter and find options from http query params, i.e.
+// ?filter=age>30,name=foo&sort=-created&limit=20&skip=40&fields=name,age
+// Filter parsed with PrepFilter, sort with PrepSortStrict and fields with PrepProjection.
+// Fields of elemMatch projection, i.e. status for items{status=active}, checked as items.status against FilterFields.
+func PrepFind(q url.Values, opts QueryOptions) (filter bson.D, fo *options.FindOptions, err error) {
+	filter, err = prepFilter(strings.Join(q[QueryFilter], ","), opts.FilterFields, opts.AllowAll)
+	if err != nil {
+		return nil, nil, &QueryError{Param: QueryFilter, Err: err}
+	}
+
+	fo = options.Find()
+	sort := q[QuerySort]
+	if len(sort) == 0 && opts.DefaultSort != "" {
+		sort = []string{opts.DefaultSort}
+	}
+	sortDoc, err := prepSortStrict(opts.SortFields, opts.AllowAll, sort...)
+	if err != nil {
+		return nil, nil, &QueryError{Param: QuerySort, Err: err}
+	}
+	if len(sortDoc) > 0 {
+		fo.SetSort(sortDoc)
+	}
+
+	if err = setQueryLimits(fo, q, opts); err != nil {
+		return nil, nil, err
+	}
+
+	proj, err := PrepProjection(q[QueryFields]...)
+	if err != nil {
+		return nil, nil, &QueryError{Param: QueryFields, Err: err}
+	}
+	if err := checkProjectionAllowed(proj, opts); err != nil {
+		return nil, nil, &QueryError{Param: QueryFields, Err: err}
+	}
+	if len(proj) > 0 {
+		fo.SetProjection(proj)
+	}
+	return filter, fo, nil
+}
+
+// setQueryLimits sets limit and skip from query params, limit defaulted and reduced to max
+func setQueryLimits(fo *options.FindOptions, q url.Values, opts QueryOptions) error {
+	limit, err := queryInt(q, QueryLimit, opts.DefaultLimit)
+	if err != nil {
+		return err
+	}
+	if opts.MaxLimit > 0 && (limit == 0 || limit > opts.MaxLimit) {
+		limit = opts.MaxLimit
+	}
+	if limit > 0 {
+		fo.SetLimit(limit)
+	}
+
+	skip, err := queryInt(q, QuerySkip, 0)
+	if err != nil {
+		return err
+	}
+	if skip > 0 {
+		fo.SetSkip(skip)
+	}
+	return nil
+}
+
+// queryInt returns non-negative int param, def if not set
+func queryInt(q url.Values, param string, def int64) (int64, error) {
+	v := strings.TrimSpace(q.Get(param))
+	if v == "" {
+		return def, nil
+	}
+	res, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || res < 0 {
+		return 0, &QueryError{Param: param, Err: fmt.Errorf("%q is not a non-negative number", v)}
+	}
+	return res, nil
+}
+
+// checkProjectionAllowed checks all projected fields, except _id, are in ProjectionFields and fields of elemMatch
+// filters, prefixed with the projected field, in FilterFields. Empty list allows no field, AllowAll skips the check
+func checkProjectionAllowed(proj bson.D, opts QueryOptions) error {
+	if opts.AllowAll {
+		return nil
+	}
+	isAllowed := func(allowed []string, field string) bool {
+		for _, f := range allowed {
+			if f == field {
+				return true
+			}
+		}
+		return false
+	}
+	var errs []error
+	for _, e := range proj {
+		if e.Key != "_id" && !isAllowed(opts.ProjectionFields, e.Key) {
+			errs = append(errs, fmt.Errorf("field %q not allowed", e.Key))
+		}
+		for _, f := range elemMatchFields(e.Value) {
+			if !isAllowed(opts.FilterFields, e.Key+"."+f) {
+				errs = append(errs, fmt.Errorf("elemMatch field %q not allowed", e.Key+"."+f))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// elemMatchFields returns filter fields of {$elemMatch: filter} projection value, nil for other values
+func elemMatchFields(v interface{}) []string {
+	d, ok := v.(bson.D)
+	if !ok || len(d) != 1 || d[0].Key != "$elemMatch" {
+		return nil
+	}
+	filter, _ := d[0].Value.(bson.D)
+	res := make([]string, 0, len(filter))
+	for _, e := range filter {
+		res = append(res, e.Key)
+	}
+	return res
+}
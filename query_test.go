@@ -0,0 +1,155 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestPrepFind(t *testing.T) {
+	opts := QueryOptions{DefaultLimit: 20, MaxLimit: 100, DefaultSort: "-created",
+		SortFields: map[string]string{"created": "meta.created", "name": ""}, FilterFields: []string{"name", "age"},
+		ProjectionFields: []string{"name", "age", "comments"}}
+
+	q, err := url.ParseQuery("filter=age>30&filter=name=foo&sort=name,-created&limit=50&skip=40&fields=name,comments[2]")
+	require.NoError(t, err)
+	filter, fo, err := PrepFind(q, opts)
+	require.NoError(t, err)
+	assert.Equal(t, bson.D{{"age", bson.D{{"$gt", int64(30)}}}, {"name", "foo"}}, filter)
+	assert.Equal(t, bson.D{{"name", 1}, {"meta.created", -1}}, fo.Sort)
+	assert.Equal(t, int64(50), *fo.Limit)
+	assert.Equal(t, int64(40), *fo.Skip)
+	assert.Equal(t, bson.D{{"name", 1}, {"comments", bson.D{{"$slice", 2}}}}, fo.Projection)
+
+	filter, fo, err = PrepFind(url.Values{}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, bson.D{}, filter)
+	assert.Equal(t, bson.D{{"meta.created", -1}}, fo.Sort, "default sort")
+	assert.Equal(t, int64(20), *fo.Limit, "default limit")
+	assert.Nil(t, fo.Skip)
+	assert.Nil(t, fo.Projection)
+
+	_, fo, err = PrepFind(url.Values{"limit": {"1000"}}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), *fo.Limit, "limited by max")
+	_, fo, err = PrepFind(url.Values{"limit": {"0"}}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), *fo.Limit, "no limit replaced by max")
+
+	filter, fo, err = PrepFind(url.Values{"filter": {"any>1"}, "sort": {"-any"}, "fields": {"-password"}},
+		QueryOptions{SortFields: map[string]string{"created": "meta.created"}, AllowAll: true})
+	require.NoError(t, err)
+	assert.Equal(t, bson.D{{"any", bson.D{{"$gt", int64(1)}}}}, filter)
+	assert.Equal(t, bson.D{{"any", -1}}, fo.Sort, "any field allowed")
+	assert.Nil(t, fo.Limit, "no default and max limits")
+	assert.Equal(t, bson.D{{"password", 0}}, fo.Projection)
+	_, fo, err = PrepFind(url.Values{"sort": {"created"}},
+		QueryOptions{SortFields: map[string]string{"created": "meta.created"}, AllowAll: true})
+	require.NoError(t, err)
+	assert.Equal(t, bson.D{{"meta.created", 1}}, fo.Sort, "whitelisted fields still mapped")
+
+	_, fo, err = PrepFind(url.Values{"fields": {"items{status=active,qty>5}"}},
+		QueryOptions{ProjectionFields: []string{"items"}, FilterFields: []string{"items.status", "items.qty"}})
+	require.NoError(t, err)
+	assert.Equal(t, bson.D{{"items", bson.D{{"$elemMatch", bson.D{{"status", "active"}, {"qty", bson.D{{"$gt", int64(5)}}}}}}}},
+		fo.Projection, "elemMatch fields allowed by filter whitelist")
+
+	filter, fo, err = PrepFind(url.Values{"fields": {"-_id"}}, QueryOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, bson.D{}, filter)
+	assert.Nil(t, fo.Sort)
+	assert.Equal(t, bson.D{{"_id", 0}}, fo.Projection, "_id allowed with empty whitelist")
+}
+
+func TestPrepFind_EmptyWhitelists(t *testing.T) {
+	tbl := []struct {
+		query string
+		err   string
+	}{
+		{"filter=name=foo", `invalid filter: invalid filter condition "name=foo": field "name" not allowed`},
+		{"sort=name", `invalid sort: sort field "name" not allowed`},
+		{"fields=name", `invalid fields: field "name" not allowed`},
+		{"fields=-secret", `invalid fields: field "secret" not allowed`},
+		{"fields=_id{secret=x}", `invalid fields: elemMatch field "_id.secret" not allowed`},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+			_, _, err = PrepFind(q, QueryOptions{})
+			assert.EqualError(t, err, tt.err)
+
+			_, _, err = PrepFind(q, QueryOptions{AllowAll: true})
+			assert.NoError(t, err)
+		})
+	}
+
+	_, _, err := PrepFind(url.Values{"filter": {"$where=1"}}, QueryOptions{AllowAll: true})
+	assert.EqualError(t, err, `invalid filter: invalid filter condition "$where=1": field "$where" can't contain $`)
+	_, _, err = PrepFind(url.Values{"sort": {"$natural"}}, QueryOptions{AllowAll: true})
+	assert.EqualError(t, err, `invalid sort: sort field "$natural" can't start with $`)
+}
+
+func TestPrepFind_Errors(t *testing.T) {
+	opts := QueryOptions{SortFields: map[string]string{"name": ""}, FilterFields: []string{"name"},
+		ProjectionFields: []string{"name"}}
+
+	tbl := []struct {
+		query string
+		param string
+		err   string
+	}{
+		{"filter=password=x", "filter", `invalid filter: invalid filter condition "password=x": field "password" not allowed`},
+		{"filter=$where=1", "filter", `invalid filter: invalid filter condition "$where=1": field "$where" can't contain $`},
+		{"sort=-age", "sort", `invalid sort: sort field "age" not allowed`},
+		{"sort=-", "sort", `invalid sort: empty sort field in "-"`},
+		{"limit=ten", "limit", `invalid limit: "ten" is not a non-negative number`},
+		{"limit=-5", "limit", `invalid limit: "-5" is not a non-negative number`},
+		{"skip=1.5", "skip", `invalid skip: "1.5" is not a non-negative number`},
+		{"fields=name,-_id,age,secret", "fields", "invalid fields: field \"age\" not allowed\nfield \"secret\" not allowed"},
+		{"fields=name,-age", "fields", `invalid fields: can't mix inclusion of "name" and exclusion of "age" in projection`},
+		{"fields=name{secret=x}", "fields", `invalid fields: elemMatch field "name.secret" not allowed`},
+		{"fields=items{secret=x}", "fields",
+			"invalid fields: field \"items\" not allowed\nelemMatch field \"items.secret\" not allowed"},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+			_, _, err = PrepFind(q, opts)
+			require.Error(t, err)
+			assert.EqualError(t, err, tt.err)
+			qe := &QueryError{}
+			require.True(t, errors.As(err, &qe))
+			assert.Equal(t, tt.param, qe.Param)
+		})
+	}
+}
+
+func TestPrepFind_Find(t *testing.T) {
+	_, coll, teardown := MakeTestConnection(t)
+	defer teardown()
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		_, err := coll.InsertOne(ctx, bson.M{"name": "n" + string(rune('0'+i)), "age": i * 10, "secret": "x"})
+		require.NoError(t, err)
+	}
+
+	q, err := url.ParseQuery("filter=age>=20&sort=-age&limit=3&skip=1&fields=name,-_id")
+	require.NoError(t, err)
+	filter, fo, err := PrepFind(q, QueryOptions{MaxLimit: 10, AllowAll: true})
+	require.NoError(t, err)
+	cursor, err := coll.Find(ctx, filter, fo)
+	require.NoError(t, err)
+	res := []bson.M{}
+	require.NoError(t, cursor.All(ctx, &res))
+	assert.Equal(t, []bson.M{{"name": "n8"}, {"name": "n7"}, {"name": "n6"}}, res)
+}
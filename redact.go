@@ -0,0 +1,162 @@
+package mongo
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+const redacted = "*****"
+
+// sensitiveOptions lists url options with secret values, in lower case as mongo options are case-insensitive
+var sensitiveOptions = map[string]bool{
+	"tlscertificatekeyfilepassword":   true,
+	"sslclientcertificatekeypassword": true,
+	"authmechanismproperties":         true,
+}
+
+// mongoURI is a connection string split to parts. All parts kept in the original, percent-encoded form
+type mongoURI struct {
+	scheme      string // mongodb or mongodb+srv
+	user        string
+	password    string
+	hasPassword bool
+	hosts       string // comma separated list of hosts, with optional ports
+	path        string // with leading slash, empty if not set
+	query       string // without leading question mark, empty if not set
+}
+
+// parseMongoURI splits mongo url to parts. Unlike url.Parse it supports multiple hosts. Userinfo ends with the first @,
+// as the driver splits it, and extends to the last @ before the host list, so not encoded /, ?, # or @ in the password
+// stay in the password.
+func parseMongoURI(u string) (res mongoURI, err error) {
+	switch {
+	case strings.HasPrefix(u, "mongodb://"):
+		res.scheme = "mongodb"
+	case strings.HasPrefix(u, "mongodb+srv://"):
+		res.scheme = "mongodb+srv"
+	default:
+		return mongoURI{}, errors.New(`scheme must be "mongodb" or "mongodb+srv"`)
+	}
+	rest := u[len(res.scheme)+len("://"):]
+
+	if at := userInfoEnd(rest); at >= 0 {
+		userInfo := rest[:at]
+		rest = rest[at+1:]
+		res.user = userInfo
+		if colon := strings.Index(userInfo, ":"); colon >= 0 {
+			res.user, res.password, res.hasPassword = userInfo[:colon], userInfo[colon+1:], true
+		}
+	}
+
+	// host list ends with the first / or ?
+	authEnd := len(rest)
+	if idx := strings.IndexAny(rest, "/?"); idx >= 0 {
+		authEnd = idx
+	}
+	authority, tail := rest[:authEnd], rest[authEnd:]
+	if authority == "" {
+		return mongoURI{}, errors.New("empty host")
+	}
+	res.hosts = authority
+
+	if q := strings.Index(tail, "?"); q >= 0 {
+		res.path, res.query = tail[:q], tail[q+1:]
+	} else {
+		res.path = tail
+	}
+	return res, nil
+}
+
+// userInfoEnd returns position of @ ending userinfo in url without scheme, -1 if there is no userinfo.
+// It is the first @, or the last @ of the host list following it, i.e. for not encoded "user:p@ss@host"
+func userInfoEnd(rest string) int {
+	at := strings.Index(rest, "@")
+	if at < 0 {
+		return -1
+	}
+	hosts := rest[at+1:]
+	if idx := strings.IndexAny(hosts, "/?"); idx >= 0 {
+		hosts = hosts[:idx]
+	}
+	return at + 1 + strings.LastIndex(hosts, "@")
+}
+
+// String assembles url back from parts
+func (m mongoURI) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(m.scheme + "://")
+	if m.user != "" || m.hasPassword {
+		sb.WriteString(m.user)
+		if m.hasPassword {
+			sb.WriteString(":" + m.password)
+		}
+		sb.WriteString("@")
+	}
+	sb.WriteString(m.hosts + m.path)
+	if m.query != "" {
+		sb.WriteString("?" + m.query)
+	}
+	return sb.String()
+}
+
+// options returns raw key/value pairs from the query, in the original order
+func (m mongoURI) options() (res [][2]string) {
+	if m.query == "" {
+		return nil
+	}
+	for _, kv := range strings.Split(m.query, "&") {
+		if kv == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(kv, "=")
+		res = append(res, [2]string{k, v})
+	}
+	return res
+}
+
+// isSensitiveOption checks if raw (possibly percent-encoded) option key holds a secret value
+func isSensitiveOption(key string) bool {
+	return sensitiveOptions[strings.ToLower(key)]
+}
+
+// RedactURL returns mongo url safe for logging, with the password and sensitive options,
+// like tlsCertificateKeyFilePassword and authMechanismProperties, replaced by *****.
+// Works with mongodb and mongodb+srv schemes, multiple hosts and percent-encoded values.
+// Returns "<invalid url>" if url can't be parsed.
+func RedactURL(u string) string {
+	if u == "" {
+		return ""
+	}
+	m, err := parseMongoURI(u)
+	if err != nil {
+		return "<invalid url>"
+	}
+	if m.hasPassword {
+		m.password = redacted
+	}
+
+	opts := m.options()
+	pairs := make([]string, 0, len(opts))
+	for _, kv := range opts {
+		if isSensitiveOption(kv[0]) {
+			kv[1] = redacted
+		}
+		if kv[1] == "" {
+			pairs = append(pairs, kv[0])
+			continue
+		}
+		pairs = append(pairs, kv[0]+"="+kv[1])
+	}
+	m.query = strings.Join(pairs, "&")
+	return m.String()
+}
+
+// redactErr drops raw url from *url.Error, as it may contain credentials
+func redactErr(err error) error {
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		return ue.Err
+	}
+	return err
+}
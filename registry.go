@@ -0,0 +1,143 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RegistryOptions defines optional parameters of Registry
+type RegistryOptions struct {
+	ClientOptions  map[string]*options.ClientOptions // per-name client options, options.Client() if not set
+	Extras         []string                          // extra url params, removed from each url and kept per name
+	ConnectOptions []ConnectOption                   // options for each connect, i.e. WithRetry
+}
+
+// Registry holds named mongo connections, i.e. to primary, analytics and audit clusters.
+// Connections made lazily on the first Client call or eagerly with Connect. Thread safe.
+type Registry struct {
+	entries map[string]*registryEntry
+	copts   []ConnectOption
+
+	lock      sync.Mutex
+	connected []string // names in order of connection, disconnected in reverse order
+	closed    bool
+}
+
+type registryEntry struct {
+	name     string
+	mongoURL string // url without extras
+	opts     *options.ClientOptions
+	extras   map[string]interface{}
+
+	lock   sync.Mutex
+	client *driver.Client
+}
+
+// NewRegistry makes registry for named urls. Urls parsed immediately, but not connected.
+func NewRegistry(urls map[string]string, opts RegistryOptions) (*Registry, error) {
+	res := &Registry{entries: make(map[string]*registryEntry, len(urls)), copts: opts.ConnectOptions}
+	for name, u := range urls {
+		mongoURL, extMap, err := parseExtMongoURI(u, opts.Extras)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse mongo url for %q: %w", name, redactErr(err))
+		}
+		clientOpts := opts.ClientOptions[name]
+		if clientOpts == nil {
+			clientOpts = options.Client()
+		}
+		res.entries[name] = &registryEntry{name: name, mongoURL: mongoURL, opts: clientOpts, extras: extMap}
+	}
+	return res, nil
+}
+
+// Names returns sorted names of all registered connections
+func (r *Registry) Names() []string {
+	res := make([]string, 0, len(r.entries))
+	for name := range r.entries {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Connect makes all connections not connected yet, in order of names
+func (r *Registry) Connect(ctx context.Context) error {
+	for _, name := range r.Names() {
+		if _, err := r.Client(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Client returns client for given name, connecting it on the first call
+func (r *Registry) Client(ctx context.Context, name string) (*driver.Client, error) {
+	e, ok := r.entries[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown mongo connection %q", name)
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.client != nil {
+		return e.client, nil
+	}
+
+	r.lock.Lock()
+	closed := r.closed
+	r.lock.Unlock()
+	if closed {
+		return nil, fmt.Errorf("registry closed, can't connect %q", name)
+	}
+
+	client, err := connect(ctx, e.opts, e.mongoURL, r.copts)
+	if err != nil {
+		return nil, fmt.Errorf("can't connect %q: %w", name, err)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed { // registry closed while connecting
+		_ = client.Disconnect(ctx)
+		return nil, fmt.Errorf("registry closed, can't connect %q", name)
+	}
+	e.client = client
+	r.connected = append(r.connected, name)
+	return client, nil
+}
+
+// Extras returns extra url params for given name
+func (r *Registry) Extras(name string) (map[string]interface{}, bool) {
+	e, ok := r.entries[name]
+	if !ok {
+		return nil, false
+	}
+	return e.extras, true
+}
+
+// Close disconnects all connected clients in reverse order of connection
+func (r *Registry) Close(ctx context.Context) error {
+	r.lock.Lock()
+	r.closed = true
+	connected := r.connected
+	r.connected = nil
+	r.lock.Unlock()
+
+	var errs []error
+	for i := len(connected) - 1; i >= 0; i-- {
+		e := r.entries[connected[i]]
+		e.lock.Lock()
+		if err := e.client.Disconnect(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("can't disconnect %q: %w", e.name, err))
+		}
+		e.client = nil
+		e.lock.Unlock()
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,85 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RetryOptions defines how connect and ping are retried. Zero values replaced by defaults,
+// unlimited attempts mean retrying till the context passed to connect is done.
+type RetryOptions struct {
+	MaxAttempts    int                  // max number of attempts, 0 for unlimited
+	InitialDelay   time.Duration        // delay after the first failed attempt, default 100ms
+	MaxDelay       time.Duration        // max delay between attempts, default 10s
+	Jitter         float64              // random part of delay, 0.1 means ±10%, 0 for no jitter
+	AttemptTimeout time.Duration        // timeout for a single attempt, 0 for no timeout
+	Retryable      func(err error) bool // decides if error can be retried, nil retries all errors
+}
+
+// WithRetry sets retry policy for connect and ping with exponential backoff
+func WithRetry(r RetryOptions) ConnectOption {
+	return func(p *connectParams) {
+		p.retry = &r
+	}
+}
+
+// connectRetry calls connectOnce till success, attempts exhausted, non-retryable error or context done
+func connectRetry(ctx context.Context, opts *options.ClientOptions, safeURL string, r RetryOptions) (*driver.Client, error) {
+	if r.InitialDelay <= 0 {
+		r.InitialDelay = 100 * time.Millisecond
+	}
+	if r.MaxDelay <= 0 {
+		r.MaxDelay = 10 * time.Second
+	}
+
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if r.AttemptTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, r.AttemptTimeout)
+		}
+		res, err := connectOnce(attemptCtx, opts, safeURL)
+		cancel()
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("[INFO] connected to %s, attempt %d", safeURL, attempt)
+			}
+			return res, nil
+		}
+
+		if r.Retryable != nil && !r.Retryable(err) {
+			return nil, err
+		}
+		if r.MaxAttempts > 0 && attempt >= r.MaxAttempts {
+			return nil, fmt.Errorf("%w, %d attempts", err, attempt)
+		}
+
+		delay := r.delay(attempt)
+		log.Printf("[WARN] connect to %s failed, attempt %d, retry in %v: %v", safeURL, attempt, delay, err)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w, %d attempts: %w", err, attempt, ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// delay returns backoff delay after given attempt, doubling it each time up to MaxDelay, with jitter applied
+func (r RetryOptions) delay(attempt int) time.Duration {
+	res := r.InitialDelay
+	for i := 1; i < attempt && res < r.MaxDelay; i++ {
+		res *= 2
+	}
+	if res > r.MaxDelay {
+		res = r.MaxDelay
+	}
+	if r.Jitter > 0 {
+		res += time.Duration(float64(res) * r.Jitter * (2*rand.Float64() - 1))
+	}
+	return res
+}
@@ -0,0 +1,248 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ClientProvider returns current mongo client. Implemented by ClientManager
+type ClientProvider interface {
+	Client() *driver.Client
+}
+
+// CredentialSource provides current credentials for mongo connection
+type CredentialSource interface {
+	Credential(ctx context.Context) (options.Credential, error)
+}
+
+// CredentialFunc is an adapter to use ordinary function as CredentialSource
+type CredentialFunc func(ctx context.Context) (options.Credential, error)
+
+// Credential calls f(ctx)
+func (f CredentialFunc) Credential(ctx context.Context) (options.Credential, error) {
+	return f(ctx)
+}
+
+// FileCredentials reads password from the file, i.e. mounted secret. Leading and trailing spaces trimmed.
+// Username taken from Username field or, if empty, from the url.
+type FileCredentials struct {
+	Path     string
+	Username string
+}
+
+// Credential reads the password from the file
+func (f FileCredentials) Credential(context.Context) (options.Credential, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return options.Credential{}, fmt.Errorf("can't read credentials file: %w", err)
+	}
+	passwd := strings.TrimSpace(string(data))
+	if passwd == "" {
+		return options.Credential{}, fmt.Errorf("empty password in %s", f.Path)
+	}
+	return options.Credential{Username: f.Username, Password: passwd, PasswordSet: true}, nil
+}
+
+// WithCredential overrides credentials from the url. Empty fields of cred keep values from the url
+func WithCredential(cred options.Credential) ConnectOption {
+	return func(p *connectParams) {
+		p.credential = &cred
+	}
+}
+
+// mergeCredential sets non-empty username, password, auth source and mechanism from cred to a copy of base
+func mergeCredential(base *options.Credential, cred options.Credential) options.Credential {
+	res := options.Credential{}
+	if base != nil {
+		res = *base
+	}
+	if cred.Username != "" {
+		res.Username = cred.Username
+	}
+	if cred.Password != "" || cred.PasswordSet {
+		res.Password, res.PasswordSet = cred.Password, true
+	}
+	if cred.AuthSource != "" {
+		res.AuthSource = cred.AuthSource
+	}
+	if cred.AuthMechanism != "" {
+		res.AuthMechanism = cred.AuthMechanism
+	}
+	return res
+}
+
+// sameCredential compares fields used by mergeCredential
+func sameCredential(a, b options.Credential) bool {
+	return a.Username == b.Username && a.Password == b.Password && a.PasswordSet == b.PasswordSet &&
+		a.AuthSource == b.AuthSource && a.AuthMechanism == b.AuthMechanism
+}
+
+// ClientManagerOptions defines optional parameters of ClientManager
+type ClientManagerOptions struct {
+	CheckInterval  time.Duration   // how often credential source checked, default 1m
+	DrainDelay     time.Duration   // delay before disconnect of the old client, for in-flight callers, default 5s
+	DrainTimeout   time.Duration   // max wait for operations in progress on disconnect of the old client, default 30s
+	ConnectOptions []ConnectOption // options for each connect, i.e. WithRetry
+}
+
+// credentialConnectFunc makes new client with given credentials
+type credentialConnectFunc func(ctx context.Context, cred options.Credential) (*driver.Client, error)
+
+// ClientManager keeps mongo client with rotated credentials. It checks CredentialSource periodically and,
+// on change, connects new client, swaps it in atomically and disconnects the old one after drain delay.
+// Callers should get the client with Client() for each operation instead of keeping it. Thread safe.
+type ClientManager struct {
+	opts     *options.ClientOptions
+	mongoURL string
+	src      CredentialSource
+	params   ClientManagerOptions
+
+	client    atomic.Pointer[driver.Client]
+	connectFn credentialConnectFunc
+
+	lock       sync.Mutex // serializes rotations
+	credential options.Credential
+	cancel     context.CancelFunc
+	done       chan struct{}
+	drains     sync.WaitGroup
+}
+
+// NewClientManager connects to mongo url with credentials from src and starts background checks of src.
+func NewClientManager(ctx context.Context, opts *options.ClientOptions, u string, src CredentialSource,
+	params ClientManagerOptions) (*ClientManager, error) {
+	return newClientManager(ctx, opts, u, src, params, nil)
+}
+
+// newClientManager makes ClientManager with custom connect function, default connect used if connectFn is nil
+func newClientManager(ctx context.Context, opts *options.ClientOptions, u string, src CredentialSource,
+	params ClientManagerOptions, connectFn credentialConnectFunc) (*ClientManager, error) {
+
+	if params.CheckInterval <= 0 {
+		params.CheckInterval = time.Minute
+	}
+	if params.DrainDelay <= 0 {
+		params.DrainDelay = 5 * time.Second
+	}
+	if params.DrainTimeout <= 0 {
+		params.DrainTimeout = 30 * time.Second
+	}
+	if u == "" {
+		return nil, errors.New("empty url")
+	}
+	if src == nil {
+		return nil, errors.New("no credential source")
+	}
+
+	res := &ClientManager{opts: opts, mongoURL: u, src: src, params: params, done: make(chan struct{})}
+	res.connectFn = connectFn
+	if res.connectFn == nil {
+		res.connectFn = res.connect
+	}
+	cred, err := src.Credential(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't get credentials: %w", err)
+	}
+	client, err := res.connectFn(ctx, cred)
+	if err != nil {
+		return nil, err
+	}
+	res.credential = cred
+	res.client.Store(client)
+
+	watchCtx, cancel := context.WithCancel(context.Background())
+	res.cancel = cancel
+	go res.watch(watchCtx)
+	return res, nil
+}
+
+// Client returns current client
+func (m *ClientManager) Client() *driver.Client {
+	return m.client.Load()
+}
+
+// Rotate checks credential source and reconnects if credentials changed.
+// Returns true if the client was replaced.
+func (m *ClientManager) Rotate(ctx context.Context) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	cred, err := m.src.Credential(ctx)
+	if err != nil {
+		return false, fmt.Errorf("can't get credentials: %w", err)
+	}
+	if sameCredential(cred, m.credential) {
+		return false, nil
+	}
+
+	client, err := m.connectFn(ctx, cred)
+	if err != nil {
+		return false, err
+	}
+	m.credential = cred
+	old := m.client.Swap(client)
+	log.Printf("[INFO] mongo credentials changed, client for %s replaced", RedactURL(m.mongoURL))
+
+	m.drains.Add(1)
+	go func() {
+		defer m.drains.Done()
+		m.drain(old)
+	}()
+	return true, nil
+}
+
+// Close stops background checks, waits for old clients to drain and disconnects the current client
+func (m *ClientManager) Close(ctx context.Context) error {
+	m.cancel()
+	<-m.done
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.drains.Wait()
+	return m.client.Load().Disconnect(ctx)
+}
+
+// watch checks credential source every CheckInterval till ctx canceled
+func (m *ClientManager) watch(ctx context.Context) {
+	defer close(m.done)
+	ticker := time.NewTicker(m.params.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := m.Rotate(ctx); err != nil {
+				log.Printf("[WARN] mongo credentials rotation failed, %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// drain waits for DrainDelay or Close and disconnects old client, giving it DrainTimeout to finish operations in progress
+func (m *ClientManager) drain(old *driver.Client) {
+	select {
+	case <-time.After(m.params.DrainDelay):
+	case <-m.done:
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.params.DrainTimeout)
+	defer cancel()
+	if err := old.Disconnect(ctx); err != nil {
+		log.Printf("[WARN] can't disconnect old mongo client, %v", err)
+	}
+}
+
+// connect makes new client with given credentials
+func (m *ClientManager) connect(ctx context.Context, cred options.Credential) (*driver.Client, error) {
+	copts := append([]ConnectOption{}, m.params.ConnectOptions...)
+	copts = append(copts, WithCredential(cred))
+	return connect(ctx, m.opts, m.mongoURL, copts)
+}
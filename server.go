@@ -6,31 +6,106 @@ import (
 	"fmt"
 	"io"
 	"net/url"
-	"regexp"
 	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
 	driver "go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // Connect to mongo url and return client. Supports expanded url params to pass a set of custom values in the url
 func Connect(ctx context.Context, opts *options.ClientOptions, u string, extras ...string) (*driver.Client, map[string]interface{}, error) {
+	return ConnectWithOptions(ctx, opts, u, extras)
+}
+
+// ConnectOption defines optional behavior of connect functions, i.e. ConnectWithOptions, ConnectInto and Dial
+type ConnectOption func(p *connectParams)
+
+// connectParams collects all values set by ConnectOption
+type connectParams struct {
+	retry         *RetryOptions
+	collectionKey string
+	credential    *options.Credential
+	serverCheck   *ServerRequirements
+	serverInfo    *ServerInfo
+	cmdMonitors   []*event.CommandMonitor
+	poolMonitors  []*event.PoolMonitor
+	srvMonitors   []*event.ServerMonitor
+}
+
+// ConnectWithOptions is the same as Connect, but accepts extras as a slice and a list of ConnectOption
+func ConnectWithOptions(ctx context.Context, opts *options.ClientOptions, u string, extras []string,
+	copts ...ConnectOption) (*driver.Client, map[string]interface{}, error) {
+
 	mongoURL, extMap, err := parseExtMongoURI(u, extras)
 	if err != nil {
-		return nil, nil, fmt.Errorf("can't parse mongo url: %w", err)
+		return nil, nil, fmt.Errorf("can't parse mongo url %s: %w", RedactURL(u), redactErr(err))
+	}
+
+	res, err := connect(ctx, opts, mongoURL, copts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, extMap, nil
+}
+
+// connect makes mongo client for url cleaned from extras and pings the server, retrying if requested
+func connect(ctx context.Context, opts *options.ClientOptions, mongoURL string, copts []ConnectOption) (*driver.Client, error) {
+	p := connectParams{}
+	for _, opt := range copts {
+		opt(&p)
+	}
+
+	opts = options.MergeClientOptions(opts) // copy, to keep caller's options unchanged
+	opts.ApplyURI(mongoURL)
+	if len(p.cmdMonitors) > 0 {
+		opts.SetMonitor(chainCommandMonitors(append([]*event.CommandMonitor{opts.Monitor}, p.cmdMonitors...)...))
+	}
+	if len(p.poolMonitors) > 0 {
+		opts.SetPoolMonitor(chainPoolMonitors(append([]*event.PoolMonitor{opts.PoolMonitor}, p.poolMonitors...)...))
+	}
+	if len(p.srvMonitors) > 0 {
+		opts.SetServerMonitor(chainServerMonitors(append([]*event.ServerMonitor{opts.ServerMonitor}, p.srvMonitors...)...))
+	}
+	if p.credential != nil {
+		opts.SetAuth(mergeCredential(opts.Auth, *p.credential))
+	}
+	safeURL := RedactURL(mongoURL)
+
+	var res *driver.Client
+	var err error
+	if p.retry == nil {
+		res, err = connectOnce(ctx, opts, safeURL)
+	} else {
+		res, err = connectRetry(ctx, opts, safeURL, *p.retry)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	res, err := driver.Connect(ctx, opts.ApplyURI(mongoURL))
+	if p.serverCheck != nil || p.serverInfo != nil {
+		if err = checkServer(ctx, res, p); err != nil {
+			_ = res.Disconnect(context.Background())
+			return nil, fmt.Errorf("mongo server %s: %w", safeURL, err)
+		}
+	}
+	return res, nil
+}
+
+// connectOnce makes mongo client and pings the server. safeURL used for error messages only
+func connectOnce(ctx context.Context, opts *options.ClientOptions, safeURL string) (*driver.Client, error) {
+	res, err := driver.Connect(ctx, opts)
 	if err != nil {
-		return nil, nil, fmt.Errorf("can't connect to mongo: %w", err)
+		return nil, fmt.Errorf("can't connect to mongo %s: %w", safeURL, err)
 	}
 
 	if err = res.Ping(ctx, nil); err != nil {
-		return nil, nil, fmt.Errorf("can't ping mongo: %w", err)
+		_ = res.Disconnect(context.Background())
+		return nil, fmt.Errorf("can't ping mongo %s: %w", safeURL, err)
 	}
 
-	return res, extMap, nil
+	return res, nil
 }
 
 // parseExtMongoURI extracts extra params from extras list and remove them from the url.
@@ -82,7 +157,56 @@ func PrepSort(sort ...string) bson.D {
 	return res
 }
 
-// PrepIndex prepares index params for mongo driver and returns IndexModel
+// PrepSortStrict is the same as PrepSort, but checks fields and maps public names to stored paths.
+// Each sort string can have several comma-separated fields, i.e. "-created,name". Fields not in allowed map,
+// starting with $, duplicated or empty (i.e. bare "-") rejected. Empty mapped value means the stored path is the
+// same as the public name. Nil or empty allowed map rejects all fields.
+func PrepSortStrict(allowed map[string]string, sort ...string) (bson.D, error) {
+	return prepSortStrict(allowed, false, sort...)
+}
+
+// prepSortStrict implements PrepSortStrict, fields not in allowed map accepted as is if allowAll set
+func prepSortStrict(allowed map[string]string, allowAll bool, sort ...string) (bson.D, error) {
+	res := bson.D{}
+	seen := map[string]bool{}
+	for _, s := range sort {
+		for _, f := range strings.Split(s, ",") {
+			f = strings.TrimSpace(f)
+			if f == "" {
+				continue
+			}
+			dir := 1
+			switch f[0] {
+			case '-':
+				dir, f = -1, strings.TrimSpace(f[1:])
+			case '+':
+				f = strings.TrimSpace(f[1:])
+			}
+			if f == "" {
+				return nil, fmt.Errorf("empty sort field in %q", s)
+			}
+			if strings.HasPrefix(f, "$") {
+				return nil, fmt.Errorf("sort field %q can't start with $", f)
+			}
+			path, ok := allowed[f]
+			if !ok && !allowAll {
+				return nil, fmt.Errorf("sort field %q not allowed", f)
+			}
+			if path == "" {
+				path = f
+			}
+			if seen[path] {
+				return nil, fmt.Errorf("duplicate sort field %q", f)
+			}
+			seen[path] = true
+			res = append(res, bson.E{Key: path, Value: dir})
+		}
+	}
+	return res, nil
+}
+
+// PrepIndex prepares index params for mongo driver and returns IndexModel. Keys used as is, with PrepSort rules,
+// use PrepIndexStrict for special index types and errors on malformed keys
 func PrepIndex(keys ...string) driver.IndexModel {
 	return driver.IndexModel{Keys: PrepSort(keys...)}
 }
@@ -96,26 +220,119 @@ func Bind(r io.Reader, v interface{}) error {
 	return bson.UnmarshalExtJSON(body, false, v)
 }
 
-var reMongoURL = regexp.MustCompile(`mongodb(\+srv)?://([^:]+):([^@]+)@[^/]+/?.*`)
-
-// SecretsMongoUrls retrieves passwords from mongo urls.
-// this is needed to pass mongo password to the logging masking function
+// SecretsMongoUrls retrieves secrets from mongo urls.
+// this is needed to pass mongo password to the logging masking function.
+// For each url it returns the password, both percent-encoded and decoded, tlsCertificateKeyFilePassword
+// and secret values of authMechanismProperties option, i.e. AWS_SESSION_TOKEN. Empty values skipped.
 func SecretsMongoUrls(urls ...string) (res []string) {
 	res = []string{}
-	mongoPasswd := func(murl string) (string, bool) {
-		if !reMongoURL.MatchString(murl) {
-			return "", false
+	seen := map[string]bool{}
+	add := func(vals ...string) {
+		for _, v := range vals {
+			if v != "" && !seen[v] {
+				seen[v] = true
+				res = append(res, v)
+			}
 		}
-		elems := reMongoURL.FindStringSubmatch(murl)
-		if len(elems) < 4 {
-			return "", false
-		}
-		return elems[3], true
 	}
 
 	for _, u := range urls {
-		if secret, ok := mongoPasswd(u); ok {
-			res = append(res, secret)
+		m, err := parseMongoURI(u)
+		if err != nil {
+			continue
+		}
+		if m.hasPassword && m.password != "" {
+			add(m.password)
+			if decoded, err := url.PathUnescape(m.password); err == nil {
+				add(decoded)
+			}
+		}
+		for _, kv := range m.options() {
+			key, err := url.QueryUnescape(kv[0])
+			if err != nil {
+				continue
+			}
+			switch strings.ToLower(key) {
+			case "tlscertificatekeyfilepassword", "sslclientcertificatekeypassword":
+				add(kv[1])
+				if decoded, err := url.QueryUnescape(kv[1]); err == nil {
+					add(decoded)
+				}
+			case "authmechanismproperties":
+				decoded, raw := unescapeQueryRaw(kv[1])
+				for _, secret := range authPropsSecrets(decoded) {
+					add(raw(secret)...)
+					add(secret)
+				}
+			}
+		}
+	}
+	return res
+}
+
+// unescapeQueryRaw decodes percent-encoded query value, invalid escapes kept as is. Returned raw func
+// finds all occurrences of decoded substring and returns them in the original, encoded form
+func unescapeQueryRaw(val string) (decoded string, raw func(sub string) []string) {
+	buf := make([]byte, 0, len(val))
+	offsets := make([]int, 0, len(val)+1) // offset in val of each decoded byte, plus the end
+	for i := 0; i < len(val); {
+		offsets = append(offsets, i)
+		switch {
+		case val[i] == '%' && i+2 < len(val) && unhex(val[i+1]) >= 0 && unhex(val[i+2]) >= 0:
+			buf = append(buf, byte(unhex(val[i+1])<<4|unhex(val[i+2])))
+			i += 3
+		case val[i] == '+':
+			buf = append(buf, ' ')
+			i++
+		default:
+			buf = append(buf, val[i])
+			i++
+		}
+	}
+	offsets = append(offsets, len(val))
+	decoded = string(buf)
+
+	raw = func(sub string) (res []string) {
+		if sub == "" {
+			return nil
+		}
+		for start := 0; ; {
+			idx := strings.Index(decoded[start:], sub)
+			if idx < 0 {
+				return res
+			}
+			idx += start
+			res = append(res, val[offsets[idx]:offsets[idx+len(sub)]])
+			start = idx + 1
+		}
+	}
+	return decoded, raw
+}
+
+// unhex returns value of hex digit, -1 if c is not a hex digit
+func unhex(c byte) int {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c - '0')
+	case 'a' <= c && c <= 'f':
+		return int(c-'a') + 10
+	case 'A' <= c && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return -1
+}
+
+// authPropsSecrets returns secret values from authMechanismProperties, i.e. "SERVICE_NAME:mongodb,AWS_SESSION_TOKEN:xyz".
+// Property is considered secret if its name contains TOKEN, SECRET or PASSWORD.
+func authPropsSecrets(props string) (res []string) {
+	for _, prop := range strings.Split(props, ",") {
+		k, v, ok := strings.Cut(prop, ":")
+		if !ok || v == "" {
+			continue
+		}
+		k = strings.ToUpper(strings.TrimSpace(k))
+		if strings.Contains(k, "TOKEN") || strings.Contains(k, "SECRET") || strings.Contains(k, "PASSWORD") {
+			res = append(res, v)
 		}
 	}
 	return res
@@ -0,0 +1,161 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+)
+
+// Topology kinds reported by ServerInfo
+const (
+	TopologyStandalone = "standalone"
+	TopologyReplicaSet = "replicaset"
+	TopologySharded    = "sharded"
+)
+
+// ServerInfo describes mongo server, collected with hello and buildInfo commands
+type ServerInfo struct {
+	Version        string // server version, i.e. "6.0.5"
+	Topology       string // one of TopologyStandalone, TopologyReplicaSet or TopologySharded
+	ReplicaSet     string // replica set name, empty if not a replica set member
+	MinWireVersion int32
+	MaxWireVersion int32
+	MaxBSONSize    int32 // max size of a document in bytes
+	Writable       bool  // server is primary, standalone or mongos
+}
+
+// ServerRequirements defines conditions checked by WithServerCheck
+type ServerRequirements struct {
+	MinVersion        string // min server version, i.e. "4.4", empty for any
+	RequireReplicaSet bool   // replica set or sharded cluster required, i.e. for transactions and change streams
+}
+
+// WithServerCheck checks server with GetServerInfo after connect and fails if requirements not met
+func WithServerCheck(req ServerRequirements) ConnectOption {
+	return func(p *connectParams) {
+		p.serverCheck = &req
+	}
+}
+
+// WithServerInfo sets info to ServerInfo of the connected server
+func WithServerInfo(info *ServerInfo) ConnectOption {
+	return func(p *connectParams) {
+		p.serverInfo = info
+	}
+}
+
+// GetServerInfo runs hello (or isMaster for old servers) and buildInfo commands and returns ServerInfo
+func GetServerInfo(ctx context.Context, client *driver.Client) (ServerInfo, error) {
+	var hello struct {
+		SetName           string `bson:"setName"`
+		Msg               string `bson:"msg"`
+		MinWireVersion    int32  `bson:"minWireVersion"`
+		MaxWireVersion    int32  `bson:"maxWireVersion"`
+		MaxBSONObjectSize int32  `bson:"maxBsonObjectSize"`
+		IsWritablePrimary bool   `bson:"isWritablePrimary"`
+		IsMaster          bool   `bson:"ismaster"`
+	}
+	admin := client.Database("admin")
+	if err := admin.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		if err = admin.RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&hello); err != nil {
+			return ServerInfo{}, fmt.Errorf("can't run hello: %w", err)
+		}
+	}
+
+	var build struct {
+		Version string `bson:"version"`
+	}
+	if err := admin.RunCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}}).Decode(&build); err != nil {
+		return ServerInfo{}, fmt.Errorf("can't run buildInfo: %w", err)
+	}
+
+	res := ServerInfo{
+		Version:        build.Version,
+		Topology:       TopologyStandalone,
+		ReplicaSet:     hello.SetName,
+		MinWireVersion: hello.MinWireVersion,
+		MaxWireVersion: hello.MaxWireVersion,
+		MaxBSONSize:    hello.MaxBSONObjectSize,
+		Writable:       hello.IsWritablePrimary || hello.IsMaster,
+	}
+	switch {
+	case hello.Msg == "isdbgrid":
+		res.Topology = TopologySharded
+	case hello.SetName != "":
+		res.Topology = TopologyReplicaSet
+	}
+	return res, nil
+}
+
+// Check returns error if server doesn't meet requirements
+func (s ServerInfo) Check(req ServerRequirements) error {
+	if req.MinVersion != "" {
+		ok, err := s.AtLeast(req.MinVersion)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("server version %s, required %s or later", s.Version, req.MinVersion)
+		}
+	}
+	if req.RequireReplicaSet && s.Topology == TopologyStandalone {
+		return errors.New("standalone server, replica set or sharded cluster required")
+	}
+	return nil
+}
+
+// AtLeast checks if server version is equal or later than given version, i.e. "4.4" or "5.0.3".
+// Missing parts of the version treated as zeros, pre-release suffixes ignored.
+func (s ServerInfo) AtLeast(version string) (bool, error) {
+	srv, err := parseVersion(s.Version)
+	if err != nil {
+		return false, fmt.Errorf("can't parse server version: %w", err)
+	}
+	req, err := parseVersion(version)
+	if err != nil {
+		return false, fmt.Errorf("can't parse required version: %w", err)
+	}
+	for i := range srv {
+		if srv[i] != req[i] {
+			return srv[i] > req[i], nil
+		}
+	}
+	return true, nil
+}
+
+// parseVersion parses up to three numeric parts of version, i.e. "7.0.2-rc1" to [7 0 2]
+func parseVersion(v string) (res [3]int, err error) {
+	v, _, _ = strings.Cut(v, "-")
+	parts := strings.Split(v, ".")
+	if v == "" || len(parts) > 3 {
+		return res, fmt.Errorf("invalid version %q", v)
+	}
+	for i, p := range parts {
+		if res[i], err = strconv.Atoi(p); err != nil || res[i] < 0 {
+			return res, fmt.Errorf("invalid version %q", v)
+		}
+	}
+	return res, nil
+}
+
+// checkServer gets server info, checks requirements and sets info if requested
+func checkServer(ctx context.Context, client *driver.Client, p connectParams) error {
+	info, err := GetServerInfo(ctx, client)
+	if err != nil {
+		return err
+	}
+	if p.serverCheck != nil {
+		if err = info.Check(*p.serverCheck); err != nil {
+			return err
+		}
+	}
+	if p.serverInfo != nil {
+		*p.serverInfo = info
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package mongo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestServerInfo_Connect(t *testing.T) {
+	mongoURL := getMongoURL(t) + "/test"
+	ctx := context.Background()
+
+	info := ServerInfo{}
+	m, _, err := ConnectWithOptions(ctx, options.Client(), mongoURL, nil,
+		WithServerCheck(ServerRequirements{MinVersion: "3.6"}), WithServerInfo(&info))
+	require.NoError(t, err)
+	defer m.Disconnect(ctx)
+	t.Logf("%+v", info)
+	assert.NotEmpty(t, info.Version)
+	assert.NotEmpty(t, info.Topology)
+	assert.Greater(t, info.MaxWireVersion, int32(0))
+	assert.Equal(t, int32(16*1024*1024), info.MaxBSONSize)
+	assert.True(t, info.Writable)
+
+	direct, err := GetServerInfo(ctx, m)
+	require.NoError(t, err)
+	assert.Equal(t, info, direct)
+
+	_, _, err = ConnectWithOptions(ctx, options.Client(), mongoURL, nil, WithServerCheck(ServerRequirements{MinVersion: "99.0"}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "required 99.0 or later")
+}
+
+func TestServerInfo_Check(t *testing.T) {
+	tbl := []struct {
+		name string
+		info ServerInfo
+		req  ServerRequirements
+		err  string
+	}{
+		{"no requirements", ServerInfo{Version: "4.0.1", Topology: TopologyStandalone}, ServerRequirements{}, ""},
+		{"version ok", ServerInfo{Version: "5.0.3"}, ServerRequirements{MinVersion: "5.0"}, ""},
+		{"version equal", ServerInfo{Version: "5.0.0"}, ServerRequirements{MinVersion: "5"}, ""},
+		{"version rc", ServerInfo{Version: "7.0.0-rc1"}, ServerRequirements{MinVersion: "6.0.10"}, ""},
+		{"version old", ServerInfo{Version: "4.4.18"}, ServerRequirements{MinVersion: "5.0"}, "server version 4.4.18, required 5.0 or later"},
+		{"version minor old", ServerInfo{Version: "4.2.1"}, ServerRequirements{MinVersion: "4.2.2"}, "server version 4.2.1, required 4.2.2 or later"},
+		{"bad required version", ServerInfo{Version: "4.2.1"}, ServerRequirements{MinVersion: "v4"}, `can't parse required version: invalid version "v4"`},
+		{"bad server version", ServerInfo{Version: ""}, ServerRequirements{MinVersion: "4.0"}, `can't parse server version: invalid version ""`},
+		{"replica set", ServerInfo{Topology: TopologyReplicaSet}, ServerRequirements{RequireReplicaSet: true}, ""},
+		{"sharded", ServerInfo{Topology: TopologySharded}, ServerRequirements{RequireReplicaSet: true}, ""},
+		{"standalone", ServerInfo{Topology: TopologyStandalone}, ServerRequirements{RequireReplicaSet: true},
+			"standalone server, replica set or sharded cluster required"},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.info.Check(tt.req)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	v, err := parseVersion("7.0.2-rc1")
+	require.NoError(t, err)
+	assert.Equal(t, [3]int{7, 0, 2}, v)
+
+	v, err = parseVersion("4")
+	require.NoError(t, err)
+	assert.Equal(t, [3]int{4, 0, 0}, v)
+
+	for _, bad := range []string{"", "1.2.3.4", "a.b", "1..2", "-1", "4.-1"} {
+		_, err = parseVersion(bad)
+		assert.Error(t, err, bad)
+	}
+}
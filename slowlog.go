@@ -0,0 +1,171 @@
+package mongo
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	log "github.com/go-pkgz/lgr"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// SlowLogOptions defines parameters of slow commands logging
+type SlowLogOptions struct {
+	Threshold    time.Duration // log commands slower than threshold, default 100ms
+	SampleRate   float64       // fraction of slow commands to log, i.e. 0.1 for 10%, 0 logs all
+	Commands     []string      // log only listed commands, i.e. "find", "aggregate", all if empty
+	SkipCommands []string      // never log listed commands, i.e. "getMore"
+	MaxBodySize  int           // max size of command body in log line, default 512
+	LogValues    bool          // log values of command body as is, by default replaced with "?" keeping field names only
+	Logger       log.L         // logger, lgr.Printf by default
+}
+
+// WithSlowLog logs commands slower than threshold, with command name, database, collection, duration
+// and truncated, redacted command body
+func WithSlowLog(opts SlowLogOptions) ConnectOption {
+	return func(p *connectParams) {
+		p.cmdMonitors = append(p.cmdMonitors, NewSlowLogMonitor(opts))
+	}
+}
+
+// redactedCommandFields dropped from logged command bodies
+var redactedCommandFields = map[string]bool{"lsid": true, "$clusterTime": true, "$db": true, "signature": true}
+
+// slowLog keeps started commands till they finished
+type slowLog struct {
+	SlowLogOptions
+	allow, deny map[string]bool
+	inFlight    sync.Map // commandKey -> slowLogCommand
+}
+
+type slowLogCommand struct {
+	cmd bson.Raw // copy of the command, body made from it for slow commands only
+}
+
+// NewSlowLogMonitor makes command monitor logging slow commands, to be used directly with options.ClientOptions.SetMonitor
+func NewSlowLogMonitor(opts SlowLogOptions) *event.CommandMonitor {
+	if opts.Threshold <= 0 {
+		opts.Threshold = 100 * time.Millisecond
+	}
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = 512
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.Func(log.Printf)
+	}
+
+	sl := &slowLog{SlowLogOptions: opts, allow: map[string]bool{}, deny: map[string]bool{}}
+	for _, c := range opts.Commands {
+		sl.allow[c] = true
+	}
+	for _, c := range opts.SkipCommands {
+		sl.deny[c] = true
+	}
+
+	return &event.CommandMonitor{
+		Started: sl.started,
+		Succeeded: func(_ context.Context, evt *event.CommandSucceededEvent) {
+			sl.finished(&evt.CommandFinishedEvent, "")
+		},
+		Failed: func(_ context.Context, evt *event.CommandFailedEvent) {
+			sl.finished(&evt.CommandFinishedEvent, evt.Failure)
+		},
+	}
+}
+
+func (sl *slowLog) enabled(command string) bool {
+	if sl.deny[command] {
+		return false
+	}
+	return len(sl.allow) == 0 || sl.allow[command]
+}
+
+func (sl *slowLog) started(_ context.Context, evt *event.CommandStartedEvent) {
+	if !sl.enabled(evt.CommandName) {
+		return
+	}
+	// command copied as it is not available on finish and its buffer can be reused by the driver
+	cmd := slowLogCommand{cmd: append(bson.Raw(nil), evt.Command...)}
+	sl.inFlight.Store(commandKey{connID: evt.ConnectionID, requestID: evt.RequestID}, cmd)
+}
+
+func (sl *slowLog) finished(evt *event.CommandFinishedEvent, failure string) {
+	v, ok := sl.inFlight.LoadAndDelete(commandKey{connID: evt.ConnectionID, requestID: evt.RequestID})
+	if !ok || evt.Duration < sl.Threshold {
+		return
+	}
+	if sl.SampleRate > 0 && sl.SampleRate < 1 && rand.Float64() >= sl.SampleRate {
+		return
+	}
+
+	cmd := v.(slowLogCommand)
+	ns := evt.DatabaseName
+	if coll := commandCollection(cmd.cmd); coll != "" {
+		ns += "." + coll
+	}
+	body := commandBody(cmd.cmd, !sl.LogValues, sl.MaxBodySize)
+	if failure != "" {
+		sl.Logger.Logf("[WARN] slow mongo command %s %s %v, failed: %s, %s", evt.CommandName, ns, evt.Duration, failure, body)
+		return
+	}
+	sl.Logger.Logf("[WARN] slow mongo command %s %s %v, %s", evt.CommandName, ns, evt.Duration, body)
+}
+
+// commandBody returns command as relaxed extended json, without session fields and truncated to maxSize.
+// With redactValues all values, except the command itself, replaced by "?".
+func commandBody(cmd bson.Raw, redactValues bool, maxSize int) string {
+	if len(cmd) == 0 {
+		return "{}" // sensitive commands, like saslStart, come without body
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(cmd, &doc); err != nil {
+		return "{?}"
+	}
+
+	res := make(bson.D, 0, len(doc))
+	for i, e := range doc {
+		if redactedCommandFields[e.Key] {
+			continue
+		}
+		if redactValues && i > 0 {
+			e.Value = redactValue(e.Value)
+		}
+		res = append(res, e)
+	}
+
+	body, err := bson.MarshalExtJSON(res, false, false)
+	if err != nil {
+		return "{?}"
+	}
+	if len(body) <= maxSize {
+		return string(body)
+	}
+	body = body[:maxSize]
+	for len(body) > 0 && !utf8.Valid(body) {
+		body = body[:len(body)-1]
+	}
+	return string(body) + "..."
+}
+
+// redactValue replaces all leaf values with "?", keeping structure of documents and arrays
+func redactValue(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case bson.D:
+		res := make(bson.D, len(vv))
+		for i, e := range vv {
+			res[i] = bson.E{Key: e.Key, Value: redactValue(e.Value)}
+		}
+		return res
+	case bson.A:
+		res := make(bson.A, len(vv))
+		for i, e := range vv {
+			res[i] = redactValue(e)
+		}
+		return res
+	default:
+		return "?"
+	}
+}
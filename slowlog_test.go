@@ -0,0 +1,148 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type testLogger struct {
+	lock  sync.Mutex
+	lines []string
+}
+
+func (l *testLogger) Logf(format string, args ...interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) all() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return append([]string{}, l.lines...)
+}
+
+func TestSlowLog_Connect(t *testing.T) {
+	mongoURL := getMongoURL(t) + "/test"
+	ctx := context.Background()
+	lgr := &testLogger{}
+
+	var started int
+	opts := options.Client().SetMonitor(&event.CommandMonitor{Started: func(context.Context, *event.CommandStartedEvent) { started++ }})
+	m, _, err := ConnectWithOptions(ctx, opts, mongoURL, nil,
+		WithSlowLog(SlowLogOptions{Threshold: time.Nanosecond, Commands: []string{"find"}, LogValues: true, Logger: lgr}))
+	require.NoError(t, err)
+	defer m.Disconnect(ctx)
+
+	err = m.Database("test").Collection("slow_log").FindOne(ctx, bson.M{"user": "secret"}).Err()
+	require.Error(t, err)
+	lines := lgr.all()
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `[WARN] slow mongo command find test.slow_log`)
+	assert.Contains(t, lines[0], `"filter":{"user":"secret"}`)
+	assert.Greater(t, started, 0, "original monitor kept")
+}
+
+func TestSlowLog_Monitor(t *testing.T) {
+	lgr := &testLogger{}
+	mon := NewSlowLogMonitor(SlowLogOptions{Threshold: 50 * time.Millisecond, SkipCommands: []string{"getMore"},
+		Logger: lgr})
+
+	run := func(name string, reqID int64, cmd bson.D, dur time.Duration, failure string) {
+		raw, err := bson.Marshal(cmd)
+		require.NoError(t, err)
+		mon.Started(context.Background(), &event.CommandStartedEvent{Command: raw, CommandName: name, DatabaseName: "db1",
+			RequestID: reqID, ConnectionID: "conn1"})
+		fin := event.CommandFinishedEvent{CommandName: name, DatabaseName: "db1", RequestID: reqID, ConnectionID: "conn1", Duration: dur}
+		if failure != "" {
+			mon.Failed(context.Background(), &event.CommandFailedEvent{CommandFinishedEvent: fin, Failure: failure})
+			return
+		}
+		mon.Succeeded(context.Background(), &event.CommandSucceededEvent{CommandFinishedEvent: fin})
+	}
+
+	run("find", 1, bson.D{{"find", "coll1"}, {"filter", bson.D{{"user", "secret"}}}, {"lsid", bson.D{{"id", 1}}}}, time.Second, "")
+	run("find", 2, bson.D{{"find", "coll1"}}, time.Millisecond, "")
+	run("getMore", 3, bson.D{{"getMore", int64(123)}, {"collection", "coll1"}}, time.Second, "")
+	run("insert", 4, bson.D{{"insert", "coll2"}, {"documents", bson.A{bson.D{{"a", 1}}}}}, time.Second, "E11000 duplicate key")
+	run("ping", 5, bson.D{{"ping", 1}}, time.Second, "")
+
+	assert.Equal(t, []string{
+		`[WARN] slow mongo command find db1.coll1 1s, {"find":"coll1","filter":{"user":"?"}}`,
+		`[WARN] slow mongo command insert db1.coll2 1s, failed: E11000 duplicate key, {"insert":"coll2","documents":[{"a":"?"}]}`,
+		`[WARN] slow mongo command ping db1 1s, {"ping":1}`,
+	}, lgr.all())
+
+	mon.Succeeded(context.Background(), &event.CommandSucceededEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{RequestID: 100, Duration: time.Hour}})
+	assert.Len(t, lgr.all(), 3, "no log for unknown command")
+}
+
+func TestSlowLog_MonitorLogValues(t *testing.T) {
+	lgr := &testLogger{}
+	mon := NewSlowLogMonitor(SlowLogOptions{Threshold: 50 * time.Millisecond, LogValues: true, Logger: lgr})
+
+	raw, err := bson.Marshal(bson.D{{"find", "coll1"}, {"filter", bson.D{{"user", "secret"}}}})
+	require.NoError(t, err)
+	mon.Started(context.Background(), &event.CommandStartedEvent{Command: raw, CommandName: "find", DatabaseName: "db1",
+		RequestID: 1, ConnectionID: "conn1"})
+	copy(raw, make([]byte, len(raw))) // driver can reuse command buffer after Started
+	mon.Succeeded(context.Background(), &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{
+		CommandName: "find", DatabaseName: "db1", RequestID: 1, ConnectionID: "conn1", Duration: time.Second}})
+
+	assert.Equal(t, []string{`[WARN] slow mongo command find db1.coll1 1s, {"find":"coll1","filter":{"user":"secret"}}`},
+		lgr.all())
+}
+
+func TestCommandBody(t *testing.T) {
+	raw, err := bson.Marshal(bson.D{{"find", "coll1"}, {"filter", bson.D{{"name", strings.Repeat("ж", 100)}}}, {"$db", "test"}})
+	require.NoError(t, err)
+
+	assert.Equal(t, `{"find":"coll1","filter":{"name":"`+strings.Repeat("ж", 100)+`"}}`, commandBody(raw, false, 1000))
+	body := commandBody(raw, false, 40)
+	assert.Equal(t, `{"find":"coll1","filter":{"name":"жжж...`, body)
+	assert.Equal(t, `{"find":"coll1","filter":{"name":"?"}}`, commandBody(raw, true, 1000))
+	assert.Equal(t, "{}", commandBody(nil, false, 100))
+	assert.Equal(t, "{?}", commandBody(bson.Raw{1, 2, 3}, false, 100))
+}
+
+func TestChainCommandMonitors(t *testing.T) {
+	var calls []string
+	m1 := &event.CommandMonitor{
+		Started:   func(context.Context, *event.CommandStartedEvent) { calls = append(calls, "m1 started") },
+		Succeeded: func(context.Context, *event.CommandSucceededEvent) { calls = append(calls, "m1 succeeded") },
+	}
+	m2 := &event.CommandMonitor{
+		Started: func(context.Context, *event.CommandStartedEvent) { calls = append(calls, "m2 started") },
+		Failed:  func(context.Context, *event.CommandFailedEvent) { calls = append(calls, "m2 failed") },
+	}
+
+	assert.Same(t, m1, chainCommandMonitors(nil, m1))
+
+	mon := chainCommandMonitors(nil, m1, m2)
+	mon.Started(context.Background(), &event.CommandStartedEvent{})
+	mon.Succeeded(context.Background(), &event.CommandSucceededEvent{})
+	mon.Failed(context.Background(), &event.CommandFailedEvent{})
+	assert.Equal(t, []string{"m1 started", "m2 started", "m1 succeeded", "m2 failed"}, calls)
+}
+
+func TestCommandCollection(t *testing.T) {
+	raw, err := bson.Marshal(bson.D{{"find", "coll1"}, {"filter", bson.D{}}})
+	require.NoError(t, err)
+	assert.Equal(t, "coll1", commandCollection(raw))
+
+	raw, err = bson.Marshal(bson.D{{"ping", 1}})
+	require.NoError(t, err)
+	assert.Equal(t, "", commandCollection(raw))
+	assert.Equal(t, "", commandCollection(nil))
+}
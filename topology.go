@@ -0,0 +1,250 @@
+package mongo
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/description"
+)
+
+// TopologyEventType defines kind of TopologyEvent
+type TopologyEventType string
+
+// enum of topology event types
+const (
+	EventPrimaryChanged  TopologyEventType = "primary_changed"
+	EventServerDown      TopologyEventType = "server_down"
+	EventServerUp        TopologyEventType = "server_up"
+	EventHeartbeatFailed TopologyEventType = "heartbeat_failed"
+)
+
+// TopologyEvent reports a change in deployment topology
+type TopologyEvent struct {
+	Type     TopologyEventType
+	Time     time.Time
+	Address  string // server address, new primary for EventPrimaryChanged, empty if primary lost
+	Previous string // previous primary for EventPrimaryChanged, empty if there was none
+	Err      error  // failure for EventServerDown and EventHeartbeatFailed, can be nil for EventServerDown
+}
+
+// HealthStatus is an overall state of deployment
+type HealthStatus string
+
+// enum of health statuses
+const (
+	HealthUnknown  HealthStatus = "unknown"  // no topology reported yet
+	HealthOK       HealthStatus = "ok"       // all servers up
+	HealthDegraded HealthStatus = "degraded" // writable server available, but some servers down
+	HealthDown     HealthStatus = "down"     // no writable server
+)
+
+// Health is a state of deployment as seen by the driver's server monitoring
+type Health struct {
+	Status   HealthStatus   `json:"status"`
+	Topology string         `json:"topology"` // driver's topology kind, i.e. ReplicaSetWithPrimary
+	Primary  string         `json:"primary,omitempty"`
+	Servers  []ServerHealth `json:"servers"` // sorted by address
+	Updated  time.Time      `json:"updated"`
+}
+
+// ServerHealth is a state of a single server
+type ServerHealth struct {
+	Address   string        `json:"address"`
+	Kind      string        `json:"kind"` // driver's server kind, i.e. RSSecondary, Unknown if server down
+	Up        bool          `json:"up"`   // reachable and usable, ghost and other (RSMember) replica set members are not up
+	RTT       time.Duration `json:"rtt"`
+	LastError string        `json:"last_error,omitempty"`
+}
+
+// TopologyOptions defines callbacks and events channel of TopologyMonitor.
+// Callbacks called synchronously from driver's monitoring goroutines and should not block.
+type TopologyOptions struct {
+	OnPrimaryChanged  func(prev, curr string) // curr is empty if primary lost
+	OnServerDown      func(addr string, err error)
+	OnServerUp        func(addr string) // called for server recovered after being down, not on initial discovery
+	OnHeartbeatFailed func(addr string, err error)
+	EventsBuffer      int // size of Events channel, no channel made if 0
+}
+
+// TopologyMonitor tracks topology changes with driver's ServerMonitor, reports them as events and keeps
+// current health status. Thread safe
+type TopologyMonitor struct {
+	opts   TopologyOptions
+	events chan TopologyEvent
+
+	lock   sync.RWMutex
+	health Health
+	wasUp  map[string]bool // servers seen up at least once
+}
+
+// NewTopologyMonitor makes topology monitor with given options
+func NewTopologyMonitor(opts TopologyOptions) *TopologyMonitor {
+	res := &TopologyMonitor{opts: opts, health: Health{Status: HealthUnknown}, wasUp: map[string]bool{}}
+	if opts.EventsBuffer > 0 {
+		res.events = make(chan TopologyEvent, opts.EventsBuffer)
+	}
+	return res
+}
+
+// WithTopologyMonitor installs server monitor reporting topology changes to tm
+func WithTopologyMonitor(tm *TopologyMonitor) ConnectOption {
+	return func(p *connectParams) {
+		p.srvMonitors = append(p.srvMonitors, tm.ServerMonitor())
+	}
+}
+
+// Events returns channel of topology events, nil if EventsBuffer not set.
+// Events dropped if the channel is full, the channel never closed.
+func (tm *TopologyMonitor) Events() <-chan TopologyEvent {
+	return tm.events
+}
+
+// Health returns the last known health of deployment
+func (tm *TopologyMonitor) Health() Health {
+	tm.lock.RLock()
+	defer tm.lock.RUnlock()
+	res := tm.health
+	res.Servers = append([]ServerHealth{}, tm.health.Servers...)
+	return res
+}
+
+// ServerMonitor returns driver's server monitor reporting to tm
+func (tm *TopologyMonitor) ServerMonitor() *event.ServerMonitor {
+	return &event.ServerMonitor{
+		TopologyDescriptionChanged: tm.topologyChanged,
+		ServerHeartbeatFailed: func(evt *event.ServerHeartbeatFailedEvent) {
+			addr := heartbeatAddress(evt.ConnectionID)
+			if tm.opts.OnHeartbeatFailed != nil {
+				tm.opts.OnHeartbeatFailed(addr, evt.Failure)
+			}
+			tm.send(TopologyEvent{Type: EventHeartbeatFailed, Address: addr, Err: evt.Failure})
+		},
+	}
+}
+
+func (tm *TopologyMonitor) topologyChanged(evt *event.TopologyDescriptionChangedEvent) {
+	for _, e := range tm.updateHealth(topologyHealth(evt.NewDescription)) {
+		tm.notify(e)
+		tm.send(e)
+	}
+}
+
+// updateHealth stores the new health and returns events for changes from the previous one
+func (tm *TopologyMonitor) updateHealth(health Health) []TopologyEvent {
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+
+	prevPrimary := tm.health.Primary
+	prevUp := map[string]bool{}
+	for _, s := range tm.health.Servers {
+		prevUp[s.Address] = s.Up
+	}
+	tm.health = health
+
+	events := []TopologyEvent{}
+	for _, s := range health.Servers {
+		switch {
+		case prevUp[s.Address] && !s.Up:
+			var err error
+			if s.LastError != "" {
+				err = errors.New(s.LastError)
+			}
+			events = append(events, TopologyEvent{Type: EventServerDown, Address: s.Address, Err: err})
+		case !prevUp[s.Address] && s.Up && tm.wasUp[s.Address]:
+			events = append(events, TopologyEvent{Type: EventServerUp, Address: s.Address})
+		}
+		if s.Up {
+			tm.wasUp[s.Address] = true
+		}
+	}
+	if prevPrimary != health.Primary {
+		events = append(events, TopologyEvent{Type: EventPrimaryChanged, Address: health.Primary, Previous: prevPrimary})
+	}
+	return events
+}
+
+// notify calls user's callback for the event, if set
+func (tm *TopologyMonitor) notify(e TopologyEvent) {
+	switch {
+	case e.Type == EventServerDown && tm.opts.OnServerDown != nil:
+		tm.opts.OnServerDown(e.Address, e.Err)
+	case e.Type == EventServerUp && tm.opts.OnServerUp != nil:
+		tm.opts.OnServerUp(e.Address)
+	case e.Type == EventPrimaryChanged && tm.opts.OnPrimaryChanged != nil:
+		tm.opts.OnPrimaryChanged(e.Previous, e.Address)
+	}
+}
+
+// send pushes event to events channel, drops it if the channel is full
+func (tm *TopologyMonitor) send(e TopologyEvent) {
+	if tm.events == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	select {
+	case tm.events <- e:
+	default:
+	}
+}
+
+// topologyHealth makes Health from driver's topology description. Deployment is down if there is no server
+// accepting writes, i.e. primary, standalone or mongos, and degraded if some servers are not reachable.
+func topologyHealth(td description.Topology) Health {
+	res := Health{Topology: td.Kind.String(), Updated: time.Now(), Servers: make([]ServerHealth, 0, len(td.Servers))}
+	writable, down := false, 0
+	for _, s := range td.Servers {
+		sh := ServerHealth{Address: s.Addr.String(), Kind: s.Kind.String(), Up: serverUp(s.Kind), RTT: s.AverageRTT}
+		if s.LastError != nil {
+			sh.LastError = s.LastError.Error()
+		}
+		switch s.Kind {
+		case description.RSPrimary:
+			res.Primary = sh.Address
+			writable = true
+		case description.Standalone, description.Mongos, description.LoadBalancer:
+			writable = true
+		}
+		if !sh.Up {
+			down++
+		}
+		res.Servers = append(res.Servers, sh)
+	}
+	sort.Slice(res.Servers, func(i, j int) bool { return res.Servers[i].Address < res.Servers[j].Address })
+
+	switch {
+	case len(td.Servers) == 0:
+		res.Status = HealthUnknown
+	case !writable:
+		res.Status = HealthDown
+	case down > 0:
+		res.Status = HealthDegraded
+	default:
+		res.Status = HealthOK
+	}
+	return res
+}
+
+// serverUp checks if server of the kind is reachable and usable. Unknown means not reachable, RSGhost and RSMember
+// (RSOther in the spec) are members which can't serve reads or writes, i.e. still initializing, arbiters or hidden
+func serverUp(kind description.ServerKind) bool {
+	switch kind {
+	case description.Unknown, description.RSGhost, description.RSMember:
+		return false
+	default:
+		return true
+	}
+}
+
+// heartbeatAddress strips connection number from heartbeat's connection id, i.e. "host:27017[-12]" -> "host:27017"
+func heartbeatAddress(connID string) string {
+	if i := strings.LastIndex(connID, "[-"); i > 0 && strings.HasSuffix(connID, "]") {
+		return connID[:i]
+	}
+	return connID
+}
@@ -0,0 +1,135 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/address"
+	"go.mongodb.org/mongo-driver/mongo/description"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestTopologyMonitor_Connect(t *testing.T) {
+	mongoURL := getMongoURL(t) + "/test"
+	ctx := context.Background()
+
+	tm := NewTopologyMonitor(TopologyOptions{})
+	m, _, err := ConnectWithOptions(ctx, options.Client(), mongoURL, nil, WithTopologyMonitor(tm))
+	require.NoError(t, err)
+	defer m.Disconnect(ctx)
+
+	h := tm.Health()
+	t.Logf("%+v", h)
+	assert.Equal(t, HealthOK, h.Status)
+	require.NotEmpty(t, h.Servers)
+	assert.True(t, h.Servers[0].Up)
+}
+
+func TestTopologyMonitor_Events(t *testing.T) {
+	var calls []string
+	tm := NewTopologyMonitor(TopologyOptions{
+		OnPrimaryChanged: func(prev, curr string) { calls = append(calls, "primary "+prev+" -> "+curr) },
+		OnServerDown:     func(addr string, err error) { calls = append(calls, "down "+addr+" "+err.Error()) },
+		OnServerUp:       func(addr string) { calls = append(calls, "up "+addr) },
+		EventsBuffer:     10,
+	})
+	mon := tm.ServerMonitor()
+	assert.Equal(t, HealthUnknown, tm.Health().Status)
+
+	srv := func(addr string, kind description.ServerKind) description.Server {
+		res := description.Server{Addr: address.Address(addr), Kind: kind}
+		if kind == 0 {
+			res.LastError = errors.New("connection refused")
+		}
+		return res
+	}
+	topology := func(servers ...description.Server) {
+		mon.TopologyDescriptionChanged(&event.TopologyDescriptionChangedEvent{
+			NewDescription: description.Topology{Kind: description.ReplicaSet, Servers: servers}})
+	}
+
+	// initial discovery, no server up events
+	topology(srv("h1:27017", 0), srv("h2:27017", 0), srv("h3:27017", 0))
+	assert.Equal(t, HealthDown, tm.Health().Status)
+	topology(srv("h1:27017", description.RSPrimary), srv("h2:27017", description.RSSecondary), srv("h3:27017", description.RSSecondary))
+	assert.Equal(t, HealthOK, tm.Health().Status)
+	assert.Equal(t, "h1:27017", tm.Health().Primary)
+
+	// secondary down
+	topology(srv("h1:27017", description.RSPrimary), srv("h2:27017", description.RSSecondary), srv("h3:27017", 0))
+	h := tm.Health()
+	assert.Equal(t, HealthDegraded, h.Status)
+	assert.Equal(t, ServerHealth{Address: "h3:27017", Kind: "Unknown", LastError: "connection refused"}, h.Servers[2])
+
+	// failover
+	topology(srv("h1:27017", 0), srv("h2:27017", description.RSPrimary), srv("h3:27017", description.RSSecondary))
+	assert.Equal(t, "h2:27017", tm.Health().Primary)
+
+	assert.Equal(t, []string{"primary  -> h1:27017", "down h3:27017 connection refused",
+		"down h1:27017 connection refused", "up h3:27017", "primary h1:27017 -> h2:27017"}, calls)
+
+	types := []TopologyEventType{}
+	for len(tm.Events()) > 0 {
+		e := <-tm.Events()
+		assert.False(t, e.Time.IsZero())
+		types = append(types, e.Type)
+	}
+	assert.Equal(t, []TopologyEventType{EventPrimaryChanged, EventServerDown, EventServerDown, EventServerUp,
+		EventPrimaryChanged}, types)
+}
+
+func TestTopologyMonitor_Heartbeat(t *testing.T) {
+	var failed []string
+	tm := NewTopologyMonitor(TopologyOptions{EventsBuffer: 1,
+		OnHeartbeatFailed: func(addr string, err error) { failed = append(failed, addr+" "+err.Error()) }})
+	mon := chainServerMonitors(nil, tm.ServerMonitor(), &event.ServerMonitor{})
+	assert.Nil(t, mon.ServerOpening, "not set by any monitor")
+
+	mon.ServerHeartbeatFailed(&event.ServerHeartbeatFailedEvent{ConnectionID: "h1:27017[-5]", Failure: errors.New("timeout"),
+		Duration: time.Second})
+	mon.ServerHeartbeatFailed(&event.ServerHeartbeatFailedEvent{ConnectionID: "h2:27017", Failure: errors.New("eof")})
+	assert.Equal(t, []string{"h1:27017 timeout", "h2:27017 eof"}, failed)
+
+	require.Len(t, tm.Events(), 1, "second event dropped, buffer full")
+	e := <-tm.Events()
+	assert.Equal(t, EventHeartbeatFailed, e.Type)
+	assert.Equal(t, "h1:27017", e.Address)
+	assert.EqualError(t, e.Err, "timeout")
+}
+
+func TestTopologyHealth(t *testing.T) {
+	tbl := []struct {
+		name    string
+		kinds   []description.ServerKind
+		status  HealthStatus
+		primary string
+	}{
+		{"no servers", nil, HealthUnknown, ""},
+		{"standalone", []description.ServerKind{description.Standalone}, HealthOK, ""},
+		{"rs ok", []description.ServerKind{description.RSSecondary, description.RSPrimary}, HealthOK, "h1:27017"},
+		{"rs no primary", []description.ServerKind{description.RSSecondary, description.RSSecondary}, HealthDown, ""},
+		{"rs degraded", []description.ServerKind{description.RSPrimary, description.Unknown}, HealthDegraded, "h0:27017"},
+		{"rs ghost", []description.ServerKind{description.RSPrimary, description.RSGhost}, HealthDegraded, "h0:27017"},
+		{"rs member", []description.ServerKind{description.RSMember, description.RSPrimary}, HealthDegraded, "h1:27017"},
+		{"sharded degraded", []description.ServerKind{description.Unknown, description.Mongos}, HealthDegraded, ""},
+		{"all down", []description.ServerKind{description.Unknown, description.Unknown}, HealthDown, ""},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			td := description.Topology{}
+			for i, k := range tt.kinds {
+				td.Servers = append(td.Servers, description.Server{Addr: address.Address("h" + string(rune('0'+i))), Kind: k})
+			}
+			h := topologyHealth(td)
+			assert.Equal(t, tt.status, h.Status)
+			assert.Equal(t, tt.primary, h.Primary)
+			assert.Len(t, h.Servers, len(tt.kinds))
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// Tracer starts spans for mongo operations. Implement it as an adapter to the tracing backend, i.e. OpenTelemetry.
+// Start returns context with the new span, to be the parent of spans started with it
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced operation
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// span attributes, following OpenTelemetry semantic conventions for mongo
+const (
+	AttrDBSystem     = "db.system"
+	AttrDBName       = "db.name"
+	AttrDBOperation  = "db.operation"
+	AttrDBCollection = "db.mongodb.collection"
+	AttrConnectionID = "db.connection_id"
+	AttrBatchSize    = "db.mongodb.batch_size"
+)
+
+// WithTracer makes span for each command, with database, collection and command name attributes
+func WithTracer(t Tracer) ConnectOption {
+	return func(p *connectParams) {
+		p.cmdMonitors = append(p.cmdMonitors, NewTracingMonitor(t))
+	}
+}
+
+// NewTracingMonitor makes command monitor starting span on command start and ending it on success or failure.
+// Started and finished events matched by connection and request id.
+func NewTracingMonitor(t Tracer) *event.CommandMonitor {
+	spans := sync.Map{} // commandKey -> Span
+
+	finish := func(evt *event.CommandFinishedEvent, failure string) {
+		v, ok := spans.LoadAndDelete(commandKey{connID: evt.ConnectionID, requestID: evt.RequestID})
+		if !ok {
+			return
+		}
+		span := v.(Span)
+		if failure != "" {
+			span.RecordError(errors.New(failure))
+		}
+		span.End()
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			_, span := t.Start(ctx, "mongo."+evt.CommandName)
+			span.SetAttribute(AttrDBSystem, "mongodb")
+			span.SetAttribute(AttrDBName, evt.DatabaseName)
+			span.SetAttribute(AttrDBOperation, evt.CommandName)
+			if coll := commandCollection(evt.Command); coll != "" {
+				span.SetAttribute(AttrDBCollection, coll)
+			}
+			span.SetAttribute(AttrConnectionID, evt.ConnectionID)
+			spans.Store(commandKey{connID: evt.ConnectionID, requestID: evt.RequestID}, span)
+		},
+		Succeeded: func(_ context.Context, evt *event.CommandSucceededEvent) {
+			finish(&evt.CommandFinishedEvent, "")
+		},
+		Failed: func(_ context.Context, evt *event.CommandFailedEvent) {
+			finish(&evt.CommandFinishedEvent, evt.Failure)
+		},
+	}
+}
@@ -0,0 +1,132 @@
+package mongo
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type testSpan struct {
+	name   string
+	parent *testSpan
+	attrs  map[string]interface{}
+	err    error
+	ended  bool
+}
+
+type testSpanKey struct{}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *testSpan) RecordError(err error)                      { s.err = err }
+func (s *testSpan) End()                                       { s.ended = true }
+
+type testTracer struct {
+	lock  sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	s := &testSpan{name: name, attrs: map[string]interface{}{}}
+	s.parent, _ = ctx.Value(testSpanKey{}).(*testSpan)
+	t.spans = append(t.spans, s)
+	return context.WithValue(ctx, testSpanKey{}, s), s
+}
+
+func (t *testTracer) find(name string) *testSpan {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, s := range t.spans {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func TestTracer_Connect(t *testing.T) {
+	mongoURL := getMongoURL(t) + "/test"
+	ctx := context.Background()
+	tracer := &testTracer{}
+
+	m, _, err := ConnectWithOptions(ctx, options.Client(), mongoURL, nil, WithTracer(tracer))
+	require.NoError(t, err)
+	defer m.Disconnect(ctx)
+
+	wr := NewBufferedWriter(m, "test", "tracing_test", 10).WithTracer(tracer)
+	require.NoError(t, wr.Write(bson.M{"key": "val1"}))
+	require.NoError(t, wr.Write(bson.M{"key": "val2"}))
+	require.NoError(t, wr.Close())
+	defer m.Database("test").Collection("tracing_test").Drop(ctx)
+
+	flush := tracer.find("mongo.BufferedWriter.flush")
+	require.NotNil(t, flush)
+	assert.True(t, flush.ended)
+	assert.Equal(t, 2, flush.attrs[AttrBatchSize])
+	assert.Equal(t, "tracing_test", flush.attrs[AttrDBCollection])
+
+	insert := tracer.find("mongo.insert")
+	require.NotNil(t, insert)
+	assert.True(t, insert.ended)
+	assert.Equal(t, "test", insert.attrs[AttrDBName])
+	assert.Equal(t, "tracing_test", insert.attrs[AttrDBCollection])
+	assert.NoError(t, insert.err)
+	assert.Same(t, flush, insert.parent, "insert span is a child of flush span")
+	assert.Nil(t, flush.parent)
+}
+
+func TestTracingMonitor(t *testing.T) {
+	tracer := &testTracer{}
+	mon := NewTracingMonitor(tracer)
+
+	start := func(reqID int64, connID string, cmd bson.D) {
+		raw, err := bson.Marshal(cmd)
+		require.NoError(t, err)
+		mon.Started(context.Background(), &event.CommandStartedEvent{Command: raw, CommandName: cmd[0].Key, DatabaseName: "db1",
+			RequestID: reqID, ConnectionID: connID})
+	}
+
+	start(1, "c1", bson.D{{"find", "coll1"}})
+	start(1, "c2", bson.D{{"insert", "coll2"}})
+	start(2, "c1", bson.D{{"ping", 1}})
+	require.Len(t, tracer.spans, 3)
+
+	mon.Failed(context.Background(), &event.CommandFailedEvent{Failure: "duplicate key",
+		CommandFinishedEvent: event.CommandFinishedEvent{RequestID: 1, ConnectionID: "c2"}})
+	assert.True(t, tracer.spans[1].ended)
+	assert.EqualError(t, tracer.spans[1].err, "duplicate key")
+	assert.False(t, tracer.spans[0].ended)
+
+	mon.Succeeded(context.Background(), &event.CommandSucceededEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{RequestID: 1, ConnectionID: "c1"}})
+	assert.True(t, tracer.spans[0].ended)
+	assert.NoError(t, tracer.spans[0].err)
+	assert.Equal(t, map[string]interface{}{AttrDBSystem: "mongodb", AttrDBName: "db1", AttrDBOperation: "find",
+		AttrDBCollection: "coll1", AttrConnectionID: "c1"}, tracer.spans[0].attrs)
+
+	assert.Equal(t, "mongo.ping", tracer.spans[2].name)
+	assert.NotContains(t, tracer.spans[2].attrs, AttrDBCollection)
+	assert.False(t, tracer.spans[2].ended)
+
+	// span started with context of the parent span is its child
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	raw, err := bson.Marshal(bson.D{{"find", "coll1"}})
+	require.NoError(t, err)
+	mon.Started(ctx, &event.CommandStartedEvent{Command: raw, CommandName: "find", RequestID: 3, ConnectionID: "c1"})
+	require.Len(t, tracer.spans, 5)
+	assert.Same(t, parent, tracer.spans[4].parent)
+	assert.Nil(t, tracer.spans[0].parent)
+	tracer.spans = tracer.spans[:3]
+
+	// finish without start ignored
+	mon.Succeeded(context.Background(), &event.CommandSucceededEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{RequestID: 100, ConnectionID: "c1"}})
+	assert.Len(t, tracer.spans, 3)
+}
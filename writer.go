@@ -22,6 +22,8 @@ type BufferedWriter interface {
 // Collection can be customized by WithCollection method. Optional flush duration to save on interval
 type BufferedWriterMongo struct {
 	client         *driver.Client
+	provider       ClientProvider
+	tracer         Tracer
 	bufferSize     int
 	db, collection string
 	flushDuration  time.Duration
@@ -55,6 +57,19 @@ func (bw *BufferedWriterMongo) WithCollection(collection string) *BufferedWriter
 	return bw
 }
 
+// WithClientProvider sets provider of the client, i.e. ClientManager. Client requested from provider on each write to mongo,
+// so the writer picks up a new client after credentials rotation
+func (bw *BufferedWriterMongo) WithClientProvider(provider ClientProvider) *BufferedWriterMongo {
+	bw.provider = provider
+	return bw
+}
+
+// WithTracer sets tracer making span for each write to mongo, with the batch size attribute
+func (bw *BufferedWriterMongo) WithTracer(tracer Tracer) *BufferedWriterMongo {
+	bw.tracer = tracer
+	return bw
+}
+
 // WithAutoFlush sets auto flush duration
 func (bw *BufferedWriterMongo) WithAutoFlush(duration time.Duration) *BufferedWriterMongo {
 	bw.flushDuration = duration
@@ -134,8 +149,31 @@ func (bw *BufferedWriterMongo) writeBuffer() (err error) {
 		return nil
 	}
 
-	coll := bw.client.Database(bw.db).Collection(bw.collection)
-	_, err = coll.InsertMany(bw.ctx, bw.buffer)
+	ctx := bw.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if bw.tracer != nil {
+		var span Span
+		ctx, span = bw.tracer.Start(ctx, "mongo.BufferedWriter.flush") // insert command span made with ctx is a child
+		span.SetAttribute(AttrDBSystem, "mongodb")
+		span.SetAttribute(AttrDBName, bw.db)
+		span.SetAttribute(AttrDBCollection, bw.collection)
+		span.SetAttribute(AttrBatchSize, len(bw.buffer))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+			}
+			span.End()
+		}()
+	}
+
+	client := bw.client
+	if bw.provider != nil {
+		client = bw.provider.Client()
+	}
+	coll := client.Database(bw.db).Collection(bw.collection)
+	_, err = coll.InsertMany(ctx, bw.buffer)
 	return err
 }
 